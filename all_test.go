@@ -311,6 +311,74 @@ func TestReplaceAllLiteral(t *testing.T) {
 	}
 }
 
+type ReplaceFuncTest struct {
+	pattern       string
+	replacement   func(string) string
+	input, output string
+}
+
+var replaceFuncTests = []ReplaceFuncTest{
+	{"[a-c]", func(s string) string { return "x" + s + "y" }, "defabcdef", "defxayxbyxcydef"},
+	{"[a-c]+", func(s string) string { return "x" + s + "y" }, "defabcdef", "defxabcydef"},
+	{"[a-c]*", func(s string) string { return "x" + s + "y" }, "defabcdef", "xydxyexyfxabcydxyexyfxy"},
+}
+
+func TestReplaceAllFunc(t *testing.T) {
+	for _, tc := range replaceFuncTests {
+		re, err := Compile(tc.pattern)
+		if err != nil {
+			t.Errorf("Unexpected error compiling %q: %v", tc.pattern, err)
+			continue
+		}
+		actual := re.ReplaceAllStringFunc(tc.input, tc.replacement)
+		if actual != tc.output {
+			t.Errorf("%q.ReplaceFunc(%q,fn) = %q; want %q",
+				tc.pattern, tc.input, actual, tc.output)
+		}
+		// now try bytes
+		actual = string(re.ReplaceAllFunc([]byte(tc.input), func(s []byte) []byte { return []byte(tc.replacement(string(s))) }))
+		if actual != tc.output {
+			t.Errorf("%q.ReplaceFunc(%q,fn) = %q; want %q",
+				tc.pattern, tc.input, actual, tc.output)
+		}
+	}
+
+	// Run ReplaceAll tests that do not have $ expansions, with a function
+	// returning the replacement.
+	for _, tc := range replaceTests {
+		if strings.Contains(tc.replacement, "$") {
+			continue
+		}
+		re, err := Compile(tc.pattern)
+		if err != nil {
+			t.Errorf("Unexpected error compiling %q: %v", tc.pattern, err)
+			continue
+		}
+		actual := re.ReplaceAllStringFunc(tc.input, func(string) string { return tc.replacement })
+		if actual != tc.output {
+			t.Errorf("%q.ReplaceAllStringFunc(%q,fn) = %q; want %q",
+				tc.pattern, tc.input, actual, tc.output)
+		}
+		// now try bytes
+		actual = string(re.ReplaceAllFunc([]byte(tc.input), func([]byte) []byte { return []byte(tc.replacement) }))
+		if actual != tc.output {
+			t.Errorf("%q.ReplaceAllFunc(%q,fn) = %q; want %q",
+				tc.pattern, tc.input, actual, tc.output)
+		}
+	}
+}
+
+func TestReplaceAllFuncReentrant(t *testing.T) {
+	re := MustCompile(`[a-c]+`)
+	actual := re.ReplaceAllStringFunc("defabcdef", func(s string) string {
+		// Calling back into the same Regexp must not deadlock.
+		return re.ReplaceAllString(s, "x")
+	})
+	if want := "defxdef"; actual != want {
+		t.Errorf("ReplaceAllStringFunc = %q; want %q", actual, want)
+	}
+}
+
 type MetaTest struct {
 	pattern, output, literal string
 	isLiteral                bool
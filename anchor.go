@@ -0,0 +1,223 @@
+package re2
+
+import "runtime"
+
+// Anchor specifies where in the text a match must occur.
+type Anchor int
+
+const (
+	// Unanchored allows a match to occur anywhere in the text.
+	Unanchored Anchor = iota
+	// AnchorStart requires a match to begin at the start of the text.
+	AnchorStart
+	// AnchorBoth requires a match to span the entire text.
+	AnchorBoth
+)
+
+// cre2 returns the value of cre2_anchor_t corresponding to the Anchor.
+func (a Anchor) cre2() int {
+	return int(a) + 1
+}
+
+// FullMatch reports whether the entire byte slice b matches the regular
+// expression re. Unlike wrapping the expression with ^ and $, this uses re2's
+// native anchoring, which does not depend on flags such as multi-line mode and
+// does not require recompiling.
+func (re *Regexp) FullMatch(b []byte) bool {
+	return re.matchAnchored(b, "", AnchorBoth)
+}
+
+// FullMatchString reports whether the entire string s matches the regular
+// expression re.
+func (re *Regexp) FullMatchString(s string) bool {
+	return re.matchAnchored(nil, s, AnchorBoth)
+}
+
+// FullMatchSubmatch returns a slice of slices holding the text of the
+// subexpressions of re when it matches the entire byte slice b, as defined by
+// the 'Submatch' descriptions in the package comment. The first element is
+// always b itself.
+// A return value of nil indicates no match.
+func (re *Regexp) FullMatchSubmatch(b []byte) [][]byte {
+	return re.findSubmatchAnchored(b, AnchorBoth)
+}
+
+// FullMatchStringSubmatch returns a slice of strings holding the text of the
+// subexpressions of re when it matches the entire string s, as defined by the
+// 'Submatch' descriptions in the package comment. The first element is always
+// s itself.
+// A return value of nil indicates no match.
+func (re *Regexp) FullMatchStringSubmatch(s string) []string {
+	return re.findStringSubmatchAnchored(s, AnchorBoth)
+}
+
+// FullMatchSubmatchIndex returns a slice holding the index pairs identifying
+// the subexpressions of re when it matches the entire byte slice b, as defined
+// by the 'Submatch' and 'Index' descriptions in the package comment.
+// A return value of nil indicates no match.
+func (re *Regexp) FullMatchSubmatchIndex(b []byte) []int {
+	return re.findSubmatchIndexAnchored(b, "", AnchorBoth)
+}
+
+// FullMatchStringSubmatchIndex returns a slice holding the index pairs
+// identifying the subexpressions of re when it matches the entire string s, as
+// defined by the 'Submatch' and 'Index' descriptions in the package comment.
+// A return value of nil indicates no match.
+func (re *Regexp) FullMatchStringSubmatchIndex(s string) []int {
+	return re.findSubmatchIndexAnchored(nil, s, AnchorBoth)
+}
+
+// MatchPrefix reports whether the byte slice b begins with a match of the
+// regular expression re.
+func (re *Regexp) MatchPrefix(b []byte) bool {
+	return re.matchAnchored(b, "", AnchorStart)
+}
+
+// MatchPrefixString reports whether the string s begins with a match of the
+// regular expression re.
+func (re *Regexp) MatchPrefixString(s string) bool {
+	return re.matchAnchored(nil, s, AnchorStart)
+}
+
+// FindPrefix returns a slice holding the text of the match of the regular
+// expression at the beginning of b.
+// A return value of nil indicates no match.
+func (re *Regexp) FindPrefix(b []byte) []byte {
+	var dstCap [2]int
+	return matchedBytes(b, re.findIndexAnchored(b, "", AnchorStart, dstCap[:0]))
+}
+
+// FindPrefixIndex returns a two-element slice of integers defining the
+// location of the match of the regular expression at the beginning of b.
+// The match itself is at b[loc[0]:loc[1]], where loc[0] is always 0.
+// A return value of nil indicates no match.
+func (re *Regexp) FindPrefixIndex(b []byte) (loc []int) {
+	return re.findIndexAnchored(b, "", AnchorStart, nil)
+}
+
+// FindPrefixString returns a string holding the text of the match of the
+// regular expression at the beginning of s. If there is no match, the return
+// value is an empty string, but it will also be empty if the regular
+// expression successfully matches an empty string. Use FindPrefixStringIndex
+// if it is necessary to distinguish these cases.
+func (re *Regexp) FindPrefixString(s string) string {
+	var dstCap [2]int
+	return matchedString(s, re.findIndexAnchored(nil, s, AnchorStart, dstCap[:0]))
+}
+
+// FindPrefixStringIndex returns a two-element slice of integers defining the
+// location of the match of the regular expression at the beginning of s.
+// The match itself is at s[loc[0]:loc[1]], where loc[0] is always 0.
+// A return value of nil indicates no match.
+func (re *Regexp) FindPrefixStringIndex(s string) (loc []int) {
+	return re.findIndexAnchored(nil, s, AnchorStart, nil)
+}
+
+// FindPrefixSubmatch returns a slice of slices holding the text of the match
+// of the regular expression at the beginning of b and the matches, if any, of
+// its subexpressions, as defined by the 'Submatch' descriptions in the package
+// comment.
+// A return value of nil indicates no match.
+func (re *Regexp) FindPrefixSubmatch(b []byte) [][]byte {
+	return re.findSubmatchAnchored(b, AnchorStart)
+}
+
+// FindPrefixStringSubmatch returns a slice of strings holding the text of the
+// match of the regular expression at the beginning of s and the matches, if
+// any, of its subexpressions, as defined by the 'Submatch' descriptions in the
+// package comment.
+// A return value of nil indicates no match.
+func (re *Regexp) FindPrefixStringSubmatch(s string) []string {
+	return re.findStringSubmatchAnchored(s, AnchorStart)
+}
+
+// FindPrefixSubmatchIndex returns a slice holding the index pairs identifying
+// the match of the regular expression at the beginning of b and the matches,
+// if any, of its subexpressions, as defined by the 'Submatch' and 'Index'
+// descriptions in the package comment.
+// A return value of nil indicates no match.
+func (re *Regexp) FindPrefixSubmatchIndex(b []byte) []int {
+	return re.findSubmatchIndexAnchored(b, "", AnchorStart)
+}
+
+// FindPrefixStringSubmatchIndex returns a slice holding the index pairs
+// identifying the match of the regular expression at the beginning of s and
+// the matches, if any, of its subexpressions, as defined by the 'Submatch' and
+// 'Index' descriptions in the package comment.
+// A return value of nil indicates no match.
+func (re *Regexp) FindPrefixStringSubmatchIndex(s string) []int {
+	return re.findSubmatchIndexAnchored(nil, s, AnchorStart)
+}
+
+// newCStringFromEither returns a cString for the input, which is bsrc if
+// non-nil and otherwise src.
+func newCStringFromEither(abi *libre2ABI, bsrc []byte, src string) cString {
+	if bsrc != nil {
+		return newCStringFromBytes(abi, bsrc)
+	}
+	return newCString(abi, src)
+}
+
+func (re *Regexp) matchAnchored(bsrc []byte, src string, anchor Anchor) bool {
+	inst := re.startOperation(len(bsrc) + len(src))
+	defer inst.abi.endOperation()
+
+	cs := newCStringFromEither(inst.abi, bsrc, src)
+	res := match(inst, cs, anchor.cre2(), 0, 0)
+	runtime.KeepAlive(bsrc)
+	runtime.KeepAlive(src)
+	return res
+}
+
+func (re *Regexp) findIndexAnchored(bsrc []byte, src string, anchor Anchor, dstCap []int) []int {
+	inst := re.startOperation(len(bsrc) + len(src) + 8)
+	defer inst.abi.endOperation()
+
+	cs := newCStringFromEither(inst.abi, bsrc, src)
+	return inst.find(cs, anchor, dstCap)
+}
+
+func (re *Regexp) findSubmatchAnchored(b []byte, anchor Anchor) [][]byte {
+	inst := re.startOperation(len(b) + 8*re.numMatches)
+	defer inst.abi.endOperation()
+
+	cs := newCStringFromBytes(inst.abi, b)
+
+	var matches [][]byte
+
+	inst.findSubmatch(cs, anchor, func(match []int) {
+		matches = append(matches, matchedBytes(b, match))
+	})
+
+	return matches
+}
+
+func (re *Regexp) findStringSubmatchAnchored(s string, anchor Anchor) []string {
+	inst := re.startOperation(len(s) + 8*re.numMatches)
+	defer inst.abi.endOperation()
+
+	cs := newCString(inst.abi, s)
+
+	var matches []string
+
+	inst.findSubmatch(cs, anchor, func(match []int) {
+		matches = append(matches, matchedString(s, match))
+	})
+
+	return matches
+}
+
+func (re *Regexp) findSubmatchIndexAnchored(bsrc []byte, src string, anchor Anchor) []int {
+	inst := re.startOperation(len(bsrc) + len(src) + 8*re.numMatches)
+	defer inst.abi.endOperation()
+
+	cs := newCStringFromEither(inst.abi, bsrc, src)
+
+	var matches []int
+
+	inst.findSubmatch(cs, anchor, func(match []int) {
+		matches = append(matches, match...)
+	})
+
+	return matches
+}
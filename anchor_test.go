@@ -0,0 +1,123 @@
+package re2
+
+import (
+	"reflect"
+	"testing"
+)
+
+var anchorTests = []struct {
+	pat    string
+	text   string
+	full   []int
+	prefix []int
+}{
+	{`a|ab`, "ab", []int{0, 2}, []int{0, 1}},
+	{`ab`, "abc", nil, []int{0, 2}},
+	{`b`, "ab", nil, nil},
+	{`a*`, "", []int{0, 0}, []int{0, 0}},
+	{`a*`, "b", nil, []int{0, 0}},
+	{`(a)(b)?`, "a", []int{0, 1, 0, 1, -1, -1}, []int{0, 1, 0, 1, -1, -1}},
+	{`(a)(b)?`, "abc", nil, []int{0, 2, 0, 1, 1, 2}},
+	// Anchoring does not depend on multi-line mode.
+	{`(?m)b$`, "b\na", nil, []int{0, 1}},
+	{`(?m)^a`, "b\na", nil, nil},
+	{`(?s).*`, "a\nb", []int{0, 3}, []int{0, 3}},
+}
+
+func TestFullMatch(t *testing.T) {
+	for _, tc := range anchorTests {
+		re := MustCompile(tc.pat)
+
+		if got, want := re.FullMatch([]byte(tc.text)), tc.full != nil; got != want {
+			t.Errorf("%q.FullMatch(%q) = %v, want %v", tc.pat, tc.text, got, want)
+		}
+		if got, want := re.FullMatchString(tc.text), tc.full != nil; got != want {
+			t.Errorf("%q.FullMatchString(%q) = %v, want %v", tc.pat, tc.text, got, want)
+		}
+		if got := re.FullMatchSubmatchIndex([]byte(tc.text)); !reflect.DeepEqual(got, tc.full) {
+			t.Errorf("%q.FullMatchSubmatchIndex(%q) = %v, want %v", tc.pat, tc.text, got, tc.full)
+		}
+		if got := re.FullMatchStringSubmatchIndex(tc.text); !reflect.DeepEqual(got, tc.full) {
+			t.Errorf("%q.FullMatchStringSubmatchIndex(%q) = %v, want %v", tc.pat, tc.text, got, tc.full)
+		}
+		if got, want := re.FullMatchStringSubmatch(tc.text), submatchStrings(tc.text, tc.full); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q.FullMatchStringSubmatch(%q) = %q, want %q", tc.pat, tc.text, got, want)
+		}
+		if got, want := re.FullMatchSubmatch([]byte(tc.text)), submatchBytes(tc.text, tc.full); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q.FullMatchSubmatch(%q) = %q, want %q", tc.pat, tc.text, got, want)
+		}
+	}
+}
+
+func TestMatchPrefix(t *testing.T) {
+	for _, tc := range anchorTests {
+		re := MustCompile(tc.pat)
+
+		if got, want := re.MatchPrefix([]byte(tc.text)), tc.prefix != nil; got != want {
+			t.Errorf("%q.MatchPrefix(%q) = %v, want %v", tc.pat, tc.text, got, want)
+		}
+		if got, want := re.MatchPrefixString(tc.text), tc.prefix != nil; got != want {
+			t.Errorf("%q.MatchPrefixString(%q) = %v, want %v", tc.pat, tc.text, got, want)
+		}
+
+		var loc []int
+		if tc.prefix != nil {
+			loc = tc.prefix[:2]
+		}
+		if got := re.FindPrefixIndex([]byte(tc.text)); !reflect.DeepEqual(got, loc) {
+			t.Errorf("%q.FindPrefixIndex(%q) = %v, want %v", tc.pat, tc.text, got, loc)
+		}
+		if got := re.FindPrefixStringIndex(tc.text); !reflect.DeepEqual(got, loc) {
+			t.Errorf("%q.FindPrefixStringIndex(%q) = %v, want %v", tc.pat, tc.text, got, loc)
+		}
+		if loc != nil {
+			if got, want := string(re.FindPrefix([]byte(tc.text))), tc.text[:loc[1]]; got != want {
+				t.Errorf("%q.FindPrefix(%q) = %q, want %q", tc.pat, tc.text, got, want)
+			}
+			if got, want := re.FindPrefixString(tc.text), tc.text[:loc[1]]; got != want {
+				t.Errorf("%q.FindPrefixString(%q) = %q, want %q", tc.pat, tc.text, got, want)
+			}
+		} else if got := re.FindPrefix([]byte(tc.text)); got != nil {
+			t.Errorf("%q.FindPrefix(%q) = %q, want nil", tc.pat, tc.text, got)
+		}
+
+		if got := re.FindPrefixSubmatchIndex([]byte(tc.text)); !reflect.DeepEqual(got, tc.prefix) {
+			t.Errorf("%q.FindPrefixSubmatchIndex(%q) = %v, want %v", tc.pat, tc.text, got, tc.prefix)
+		}
+		if got := re.FindPrefixStringSubmatchIndex(tc.text); !reflect.DeepEqual(got, tc.prefix) {
+			t.Errorf("%q.FindPrefixStringSubmatchIndex(%q) = %v, want %v", tc.pat, tc.text, got, tc.prefix)
+		}
+		if got, want := re.FindPrefixStringSubmatch(tc.text), submatchStrings(tc.text, tc.prefix); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q.FindPrefixStringSubmatch(%q) = %q, want %q", tc.pat, tc.text, got, want)
+		}
+		if got, want := re.FindPrefixSubmatch([]byte(tc.text)), submatchBytes(tc.text, tc.prefix); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q.FindPrefixSubmatch(%q) = %q, want %q", tc.pat, tc.text, got, want)
+		}
+	}
+}
+
+func submatchStrings(s string, loc []int) []string {
+	if loc == nil {
+		return nil
+	}
+	res := make([]string, len(loc)/2)
+	for i := range res {
+		if loc[2*i] >= 0 {
+			res[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return res
+}
+
+func submatchBytes(s string, loc []int) [][]byte {
+	if loc == nil {
+		return nil
+	}
+	res := make([][]byte, len(loc)/2)
+	for i := range res {
+		if loc[2*i] >= 0 {
+			res[i] = []byte(s[loc[2*i]:loc[2*i+1]])
+		}
+	}
+	return res
+}
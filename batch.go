@@ -0,0 +1,67 @@
+package re2
+
+import "runtime"
+
+// MatchStrings reports whether each of inputs contains any match of the
+// regular expression re, as with MatchString. All of inputs are copied to re2
+// and matched in a single call, which avoids much of the overhead of calling
+// MatchString for each of many short inputs.
+func (re *Regexp) MatchStrings(inputs []string) []bool {
+	matched := make([]bool, len(inputs))
+	if len(inputs) == 0 {
+		return matched
+	}
+
+	inst := re.startOperation(batchMemorySize(inputs, 0))
+	defer inst.abi.endOperation()
+
+	texts := newCStrings(inst.abi, inputs)
+	matchBatch(inst, texts, Unanchored.cre2(), 0, 0, func(i int, _ uintptr) {
+		matched[i] = true
+	})
+	runtime.KeepAlive(inputs)
+
+	return matched
+}
+
+// FindStringIndexBatch returns the location of the leftmost match of the
+// regular expression in each of inputs, as with FindStringIndex. Element i is
+// nil if inputs[i] has no match. Like MatchStrings, all of inputs are matched
+// in a single call to re2.
+func (re *Regexp) FindStringIndexBatch(inputs []string) [][]int {
+	locs := make([][]int, len(inputs))
+	if len(inputs) == 0 {
+		return locs
+	}
+
+	inst := re.startOperation(batchMemorySize(inputs, 1))
+	defer inst.abi.endOperation()
+
+	matchArr := newCStringArray(inst.abi, len(inputs))
+	texts := newCStrings(inst.abi, inputs)
+	matchBatch(inst, texts, Unanchored.cre2(), matchArr.ptr, 1, func(i int, matchPtr uintptr) {
+		locs[i] = readMatch(inst.abi, texts[i], matchPtr, nil)
+	})
+	runtime.KeepAlive(inputs)
+
+	return locs
+}
+
+// batchMemorySize returns the memory needed to match inputs in a batch with
+// nMatches matches reported for each.
+func batchMemorySize(inputs []string, nMatches int) int {
+	// The texts array, whether each matched and the matches.
+	size := len(inputs) * (8 + 4 + 8*nMatches)
+	for _, s := range inputs {
+		size += len(s)
+	}
+	return size
+}
+
+func newCStrings(abi *libre2ABI, inputs []string) []cString {
+	texts := make([]cString, len(inputs))
+	for i, s := range inputs {
+		texts[i] = newCString(abi, s)
+	}
+	return texts
+}
@@ -0,0 +1,63 @@
+package re2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchStrings(t *testing.T) {
+	tests := []struct {
+		expr   string
+		inputs []string
+		want   []bool
+	}{
+		{`a+`, nil, []bool{}},
+		{`a+`, []string{"", "a", "b", "baaab"}, []bool{false, true, false, true}},
+		{`^$`, []string{"", "x", ""}, []bool{true, false, true}},
+		{`(?i)error: (\d+)`, []string{"ok", "ERROR: 12", "error:"}, []bool{false, true, false}},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.expr, func(t *testing.T) {
+			re := MustCompile(tt.expr)
+			got := re.MatchStrings(tt.inputs)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchStrings(%q) = %v, want %v", tt.inputs, got, tt.want)
+			}
+			for i, s := range tt.inputs {
+				if want := re.MatchString(s); got[i] != want {
+					t.Errorf("MatchStrings(%q)[%d] = %v, MatchString = %v", tt.inputs, i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFindStringIndexBatch(t *testing.T) {
+	tests := []struct {
+		expr   string
+		inputs []string
+	}{
+		{`a+`, nil},
+		{`a+`, []string{"", "a", "b", "baaab"}},
+		{`x*`, []string{"", "yxx", "xxy"}},
+		{`\d+`, []string{"abc", "a12b345", "9"}},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.expr, func(t *testing.T) {
+			re := MustCompile(tt.expr)
+			got := re.FindStringIndexBatch(tt.inputs)
+			if len(got) != len(tt.inputs) {
+				t.Fatalf("FindStringIndexBatch(%q) = %v, want %d results", tt.inputs, got, len(tt.inputs))
+			}
+			for i, s := range tt.inputs {
+				if want := re.FindStringIndex(s); !reflect.DeepEqual(got[i], want) {
+					t.Errorf("FindStringIndexBatch(%q)[%d] = %v, want %v", tt.inputs, i, got[i], want)
+				}
+			}
+		})
+	}
+}
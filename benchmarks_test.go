@@ -1,6 +1,7 @@
 package re2
 
 import (
+	"runtime"
 	"strings"
 	"testing"
 	"unicode/utf8"
@@ -319,6 +320,35 @@ func BenchmarkCompile(b *testing.B) {
 	}
 }
 
+// BenchmarkCompileRetained keeps every compiled expression alive, reporting
+// the heap retained per expression as well as the time to compile it. This is
+// the cost of compiling many expressions up front.
+func BenchmarkCompileRetained(b *testing.B) {
+	for _, data := range compileBenchData {
+		b.Run(data.name, func(b *testing.B) {
+			retained := make([]interface{}, 0, b.N)
+
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				re, err := CompileBenchmark(data.re)
+				if err != nil {
+					b.Fatal(err)
+				}
+				retained = append(retained, re)
+			}
+			b.StopTimer()
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+
+			b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/float64(b.N), "retained-B/op")
+			runtime.KeepAlive(retained)
+		})
+	}
+}
+
 func BenchmarkMatch(b *testing.B) {
 	for _, data := range benchData {
 		r := MustCompileBenchmark(data.re)
@@ -0,0 +1,58 @@
+package re2
+
+import (
+	"testing"
+)
+
+func TestClose(t *testing.T) {
+	re := MustCompile(`a(b)`)
+	if !re.MatchString("ab") {
+		t.Fatalf("MatchString(%q) = false; want true", "ab")
+	}
+	re.Close()
+	// Closing again is a no-op.
+	re.Close()
+
+	if got := re.String(); got != `a(b)` {
+		t.Errorf("String() = %q; want %q", got, `a(b)`)
+	}
+	if got := re.NumSubexp(); got != 1 {
+		t.Errorf("NumSubexp() = %d; want 1", got)
+	}
+
+	for name, f := range map[string]func(){
+		"MatchString":      func() { re.MatchString("ab") },
+		"FindAllIndex":     func() { re.FindAllIndex([]byte("ab"), -1) },
+		"ReplaceAllString": func() { re.ReplaceAllString("ab", "c") },
+		"SubexpNames":      func() { re.SubexpNames() },
+		"Longest":          func() { re.Longest() },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != errClosed {
+					t.Errorf("%s after Close: recovered %v; want %q", name, r, errClosed)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestSetClose(t *testing.T) {
+	set := NewSet(Unanchored)
+	if _, err := set.Add(`a`); err != nil {
+		t.Fatalf("Add(`a`) unexpected error: %v", err)
+	}
+	if err := set.Compile(); err != nil {
+		t.Fatalf("Compile() unexpected error: %v", err)
+	}
+	set.Close()
+	set.Close()
+
+	defer func() {
+		if r := recover(); r != errSetClosed {
+			t.Errorf("MatchString after Close: recovered %v; want %q", r, errSetClosed)
+		}
+	}()
+	set.MatchString("a")
+}
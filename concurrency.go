@@ -0,0 +1,29 @@
+package re2
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+var maxConcurrency int32
+
+// SetMaxConcurrency sets the maximum number of operations, such as matches,
+// that run at the same time in the default WebAssembly mode. Each concurrent
+// operation needs its own WebAssembly module instance, with a copy of the
+// expressions it is used with, so this bounds the memory used to match a
+// Regexp from many goroutines. Operations beyond the limit wait for another
+// to finish. If n is not positive, the limit is GOMAXPROCS, the default.
+//
+// The limit only grows the pool of module instances, so lowering it stops
+// using instances beyond the new limit but does not free their memory. With
+// cgo or TinyGo, operations are not limited and this has no effect.
+func SetMaxConcurrency(n int) {
+	atomic.StoreInt32(&maxConcurrency, int32(n))
+}
+
+func concurrencyLimit() int {
+	if n := atomic.LoadInt32(&maxConcurrency); n > 0 {
+		return int(n)
+	}
+	return runtime.GOMAXPROCS(0)
+}
@@ -0,0 +1,70 @@
+package re2
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentMatch(t *testing.T) {
+	re := MustCompile(`(\w+)@(\w+)\.com`)
+	input := "mail alice@example.com or bob@example.com"
+	want := re.FindAllStringSubmatchIndex(input, -1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if got := re.FindAllStringSubmatchIndex(input, -1); !reflect.DeepEqual(got, want) {
+					t.Errorf("FindAllStringSubmatchIndex(%q) = %v, want %v", input, got, want)
+					return
+				}
+				if got := re.SubexpNames(); len(got) != 3 {
+					t.Errorf("SubexpNames() = %q, want 3 names", got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentNewSetAndCompile(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				set := NewSet(Unanchored)
+				if _, err := set.Add(`a+`); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := set.Compile(); err != nil {
+					t.Error(err)
+					return
+				}
+				if got := set.MatchString("baa"); !reflect.DeepEqual(got, []int{0}) {
+					t.Errorf("MatchString(%q) = %v, want [0]", "baa", got)
+					return
+				}
+				set.Close()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				re := MustCompile(`(b)(a+)`)
+				if got := re.FindStringSubmatch("baa"); !reflect.DeepEqual(got, []string{"baa", "b", "aa"}) {
+					t.Errorf("FindStringSubmatch(%q) = %q", "baa", got)
+					return
+				}
+				re.Close()
+			}
+		}()
+	}
+	wg.Wait()
+}
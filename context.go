@@ -0,0 +1,189 @@
+package re2
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+)
+
+// MatchContext is like Match but stops matching when ctx is done, returning
+// ctx.Err(). With the default WebAssembly backend a match in progress is
+// interrupted. With cgo or TinyGo, re2 cannot be interrupted, so ctx is only
+// checked before matching starts. If re2 runs out of memory, an error
+// wrapping ErrMemoryLimit is returned rather than panicking.
+func (re *Regexp) MatchContext(ctx context.Context, b []byte) (matched bool, err error) {
+	err = re.runContext(ctx, len(b), func(inst *Regexp) {
+		cs := newCStringFromBytes(inst.abi, b)
+		matched = match(inst, cs, Unanchored.cre2(), 0, 0)
+		runtime.KeepAlive(b)
+	})
+	return matched, err
+}
+
+// MatchStringContext is like MatchString but stops matching when ctx is done,
+// returning ctx.Err(), as described for MatchContext.
+func (re *Regexp) MatchStringContext(ctx context.Context, s string) (matched bool, err error) {
+	err = re.runContext(ctx, len(s), func(inst *Regexp) {
+		cs := newCString(inst.abi, s)
+		matched = match(inst, cs, Unanchored.cre2(), 0, 0)
+		runtime.KeepAlive(s)
+	})
+	return matched, err
+}
+
+// FindIndexContext is like FindIndex but stops matching when ctx is done,
+// returning ctx.Err(), as described for MatchContext.
+func (re *Regexp) FindIndexContext(ctx context.Context, b []byte) (loc []int, err error) {
+	err = re.runContext(ctx, len(b)+8, func(inst *Regexp) {
+		cs := newCStringFromBytes(inst.abi, b)
+		loc = inst.find(cs, Unanchored, nil)
+	})
+	return loc, err
+}
+
+// FindStringIndexContext is like FindStringIndex but stops matching when ctx
+// is done, returning ctx.Err(), as described for MatchContext.
+func (re *Regexp) FindStringIndexContext(ctx context.Context, s string) (loc []int, err error) {
+	err = re.runContext(ctx, len(s)+8, func(inst *Regexp) {
+		cs := newCString(inst.abi, s)
+		loc = inst.find(cs, Unanchored, nil)
+	})
+	return loc, err
+}
+
+// FindSubmatchIndexContext is like FindSubmatchIndex but stops matching when
+// ctx is done, returning ctx.Err(), as described for MatchContext.
+func (re *Regexp) FindSubmatchIndexContext(ctx context.Context, b []byte) (loc []int, err error) {
+	err = re.runContext(ctx, len(b)+8*re.numMatches, func(inst *Regexp) {
+		cs := newCStringFromBytes(inst.abi, b)
+		inst.findSubmatch(cs, Unanchored, func(match []int) {
+			loc = append(loc, match...)
+		})
+	})
+	return loc, err
+}
+
+// FindStringSubmatchIndexContext is like FindStringSubmatchIndex but stops
+// matching when ctx is done, returning ctx.Err(), as described for
+// MatchContext.
+func (re *Regexp) FindStringSubmatchIndexContext(ctx context.Context, s string) (loc []int, err error) {
+	err = re.runContext(ctx, len(s)+8*re.numMatches, func(inst *Regexp) {
+		cs := newCString(inst.abi, s)
+		inst.findSubmatch(cs, Unanchored, func(match []int) {
+			loc = append(loc, match...)
+		})
+	})
+	return loc, err
+}
+
+// FindAllIndexContext is like FindAllIndex but stops matching when ctx is
+// done, returning ctx.Err(), as described for MatchContext.
+func (re *Regexp) FindAllIndexContext(ctx context.Context, b []byte, n int) (matches [][]int, err error) {
+	err = re.runContext(ctx, len(b)+findAllMemorySize(1), func(inst *Regexp) {
+		cs := newCStringFromBytes(inst.abi, b)
+		inst.findAll(cs, n, func(match []int) {
+			matches = append(matches, append([]int(nil), match...))
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// FindAllStringIndexContext is like FindAllStringIndex but stops matching when
+// ctx is done, returning ctx.Err(), as described for MatchContext.
+func (re *Regexp) FindAllStringIndexContext(ctx context.Context, s string, n int) (matches [][]int, err error) {
+	err = re.runContext(ctx, len(s)+findAllMemorySize(1), func(inst *Regexp) {
+		cs := newCString(inst.abi, s)
+		inst.findAll(cs, n, func(match []int) {
+			matches = append(matches, append([]int(nil), match...))
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// FindAllSubmatchIndexContext is like FindAllSubmatchIndex but stops matching
+// when ctx is done, returning ctx.Err(), as described for MatchContext.
+func (re *Regexp) FindAllSubmatchIndexContext(ctx context.Context, b []byte, n int) (matches [][]int, err error) {
+	err = re.runContext(ctx, len(b)+findAllMemorySize(re.numMatches), func(inst *Regexp) {
+		cs := newCStringFromBytes(inst.abi, b)
+		inst.findAllSubmatch(cs, n, func(match []int) {
+			matches = append(matches, append([]int(nil), match...))
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// FindAllStringSubmatchIndexContext is like FindAllStringSubmatchIndex but
+// stops matching when ctx is done, returning ctx.Err(), as described for
+// MatchContext.
+func (re *Regexp) FindAllStringSubmatchIndexContext(ctx context.Context, s string, n int) (matches [][]int, err error) {
+	err = re.runContext(ctx, len(s)+findAllMemorySize(re.numMatches), func(inst *Regexp) {
+		cs := newCString(inst.abi, s)
+		inst.findAllSubmatch(cs, n, func(match []int) {
+			matches = append(matches, append([]int(nil), match...))
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// ReplaceAllContext is like ReplaceAll but stops matching when ctx is done,
+// returning ctx.Err(), as described for MatchContext.
+func (re *Regexp) ReplaceAllContext(ctx context.Context, src, repl []byte) ([]byte, error) {
+	replRE2 := convertReplacement(string(repl), re.SubexpNames())
+
+	res := src
+	err := re.runContext(ctx, len(src)+len(replRE2)+16, func(inst *Regexp) {
+		srcCS := newCStringFromBytes(inst.abi, src)
+		if replaced, count := inst.replaceAll(srcCS, replRE2); count > 0 {
+			res = replaced
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ReplaceAllStringContext is like ReplaceAllString but stops matching when ctx
+// is done, returning ctx.Err(), as described for MatchContext.
+func (re *Regexp) ReplaceAllStringContext(ctx context.Context, src, repl string) (string, error) {
+	replRE2 := convertReplacement(repl, re.SubexpNames())
+
+	res := src
+	err := re.runContext(ctx, len(src)+len(replRE2)+16, func(inst *Regexp) {
+		srcCS := newCString(inst.abi, src)
+		if replaced, count := inst.replaceAll(srcCS, replRE2); count > 0 {
+			res = string(replaced)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return res, nil
+}
+
+// runContext runs op on re as compiled in the module instance that runs the
+// operation, returning ctx.Err() if ctx is done before op completes, or
+// ErrMemoryLimit if memory runs out. re must not be closed.
+func (re *Regexp) runContext(ctx context.Context, memorySize int, op func(inst *Regexp)) (err error) {
+	defer recoverMemoryLimit(&err)
+
+	if atomic.LoadUint32(&re.released) == 1 {
+		panic(errClosed)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return runContext(re, ctx, memorySize, op)
+}
@@ -0,0 +1,72 @@
+package re2
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+	}{
+		{`a+`, "baaac"},
+		{`(\w+)@(\w+)`, "x@y a@b"},
+		{`a*`, "baaac"},
+		{`x`, "banana"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, tc := range tests {
+		re := MustCompile(tc.pattern)
+
+		// Both a context that is never done and one that can be.
+		for _, ctx := range []context.Context{context.Background(), ctx} {
+			matched, err := re.MatchStringContext(ctx, tc.input)
+			if want := re.MatchString(tc.input); err != nil || matched != want {
+				t.Errorf("%q.MatchStringContext(%q) = (%v, %v), want (%v, nil)", tc.pattern, tc.input, matched, err, want)
+			}
+			loc, err := re.FindStringIndexContext(ctx, tc.input)
+			if want := re.FindStringIndex(tc.input); err != nil || !reflect.DeepEqual(loc, want) {
+				t.Errorf("%q.FindStringIndexContext(%q) = (%v, %v), want (%v, nil)", tc.pattern, tc.input, loc, err, want)
+			}
+			loc, err = re.FindSubmatchIndexContext(ctx, []byte(tc.input))
+			if want := re.FindStringSubmatchIndex(tc.input); err != nil || !reflect.DeepEqual(loc, want) {
+				t.Errorf("%q.FindSubmatchIndexContext(%q) = (%v, %v), want (%v, nil)", tc.pattern, tc.input, loc, err, want)
+			}
+			all, err := re.FindAllStringIndexContext(ctx, tc.input, -1)
+			if want := re.FindAllStringIndex(tc.input, -1); err != nil || !reflect.DeepEqual(all, want) {
+				t.Errorf("%q.FindAllStringIndexContext(%q) = (%v, %v), want (%v, nil)", tc.pattern, tc.input, all, err, want)
+			}
+			all, err = re.FindAllSubmatchIndexContext(ctx, []byte(tc.input), -1)
+			if want := re.FindAllStringSubmatchIndex(tc.input, -1); err != nil || !reflect.DeepEqual(all, want) {
+				t.Errorf("%q.FindAllSubmatchIndexContext(%q) = (%v, %v), want (%v, nil)", tc.pattern, tc.input, all, err, want)
+			}
+			replaced, err := re.ReplaceAllStringContext(ctx, tc.input, "<$0>")
+			if want := re.ReplaceAllString(tc.input, "<$0>"); err != nil || replaced != want {
+				t.Errorf("%q.ReplaceAllStringContext(%q) = (%q, %v), want (%q, nil)", tc.pattern, tc.input, replaced, err, want)
+			}
+		}
+	}
+}
+
+func TestContextDone(t *testing.T) {
+	re := MustCompile(`a+`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := re.MatchStringContext(ctx, "aaa"); !errors.Is(err, context.Canceled) {
+		t.Errorf("MatchStringContext with canceled context returned error %v, want %v", err, context.Canceled)
+	}
+	if all, err := re.FindAllIndexContext(ctx, []byte("aaa"), -1); all != nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("FindAllIndexContext with canceled context = (%v, %v), want (nil, %v)", all, err, context.Canceled)
+	}
+	if res, err := re.ReplaceAllContext(ctx, []byte("aaa"), []byte("b")); res != nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("ReplaceAllContext with canceled context = (%q, %v), want (nil, %v)", res, err, context.Canceled)
+	}
+}
@@ -0,0 +1,35 @@
+package re2
+
+import "runtime"
+
+// CountAll returns the number of successive matches of the expression in b,
+// the same as len(re.FindAllIndex(b, -1)). re2 finds all of the matches in a
+// single call without reporting their locations, which makes it much cheaper
+// than FindAllIndex when only the count is needed.
+func (re *Regexp) CountAll(b []byte) int {
+	inst := re.startOperation(len(b) + 16)
+	defer inst.abi.endOperation()
+
+	cs := newCStringFromBytes(inst.abi, b)
+	n := inst.countAll(cs)
+	runtime.KeepAlive(b)
+	return n
+}
+
+// CountAllString returns the number of successive matches of the expression
+// in s, the same as len(re.FindAllStringIndex(s, -1)). Like CountAll, it is
+// much cheaper than FindAllStringIndex when only the count is needed.
+func (re *Regexp) CountAllString(s string) int {
+	inst := re.startOperation(len(s) + 16)
+	defer inst.abi.endOperation()
+
+	cs := newCString(inst.abi, s)
+	n := inst.countAll(cs)
+	runtime.KeepAlive(s)
+	return n
+}
+
+func (re *Regexp) countAll(cs cString) int {
+	state := newFindAllState(re.abi, 0, -1)
+	return findAllFrom(re, cs, state, re.opts.Encoding != EncodingLatin1, 0, 0, cs.length+1)
+}
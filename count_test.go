@@ -0,0 +1,36 @@
+package re2
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCountAll(t *testing.T) {
+	tests := []struct {
+		expr  string
+		input string
+	}{
+		{`a`, ""},
+		{`a`, "banana"},
+		{`a*`, "baaab"},
+		{`x*`, "日本語"},
+		{`\b`, "hello world"},
+		{`(a)|b`, strings.Repeat("ab", 100)},
+		{``, strings.Repeat("x", 200)},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.expr, func(t *testing.T) {
+			want := len(regexp.MustCompile(tt.expr).FindAllStringIndex(tt.input, -1))
+			re := MustCompile(tt.expr)
+			if got := re.CountAllString(tt.input); got != want {
+				t.Errorf("CountAllString(%q) = %d, want %d", tt.input, got, want)
+			}
+			if got := re.CountAll([]byte(tt.input)); got != want {
+				t.Errorf("CountAll(%q) = %d, want %d", tt.input, got, want)
+			}
+		})
+	}
+}
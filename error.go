@@ -0,0 +1,64 @@
+package re2
+
+import (
+	"regexp/syntax"
+)
+
+// errLarge is syntax.ErrLarge, which is not defined before Go 1.20.
+const errLarge = syntax.ErrorCode("expression too large")
+
+// Error describes a failure to parse a regular expression. It mirrors
+// regexp/syntax.Error, and errors.As can also be used to get the error as a
+// *syntax.Error for code written against the standard library.
+type Error struct {
+	// Code is the kind of error, using the same values as regexp/syntax.
+	Code syntax.ErrorCode
+	// Expr is the fragment of the expression that caused the error.
+	Expr string
+	// Pattern is the full expression being parsed.
+	Pattern string
+}
+
+func (e *Error) Error() string {
+	return "error parsing regexp: " + e.Code.String() + ": `" + e.Expr + "`"
+}
+
+// As sets target to an equivalent *syntax.Error if it is a **syntax.Error.
+func (e *Error) As(target interface{}) bool {
+	t, ok := target.(**syntax.Error)
+	if !ok {
+		return false
+	}
+	*t = &syntax.Error{Code: e.Code, Expr: e.Expr}
+	return true
+}
+
+// errorCodes maps the error codes of re2, indexed from 1, to the equivalent
+// codes of regexp/syntax.
+var errorCodes = [...]syntax.ErrorCode{
+	syntax.ErrInternalError,
+	syntax.ErrInvalidEscape,
+	syntax.ErrInvalidCharClass,
+	syntax.ErrInvalidCharRange,
+	syntax.ErrMissingBracket,
+	syntax.ErrMissingParen,
+	syntax.ErrUnexpectedParen,
+	syntax.ErrTrailingBackslash,
+	syntax.ErrMissingRepeatArgument,
+	syntax.ErrInvalidRepeatSize,
+	syntax.ErrInvalidRepeatOp,
+	syntax.ErrInvalidPerlOp,
+	syntax.ErrInvalidUTF8,
+	syntax.ErrInvalidNamedCapture,
+	errLarge,
+}
+
+// newError returns the error for a pattern re2 failed to parse with the given
+// error code and argument.
+func newError(pattern string, code int, arg string) *Error {
+	c := syntax.ErrInternalError
+	if code > 0 && code <= len(errorCodes) {
+		c = errorCodes[code-1]
+	}
+	return &Error{Code: c, Expr: arg, Pattern: pattern}
+}
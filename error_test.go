@@ -0,0 +1,54 @@
+package re2
+
+import (
+	"errors"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestCompileError(t *testing.T) {
+	tests := []struct {
+		pattern string
+		code    syntax.ErrorCode
+		expr    string
+	}{
+		{`a(b`, syntax.ErrMissingParen, `a(b`},
+		{`abc)`, syntax.ErrUnexpectedParen, `abc)`},
+		{`x[a-z`, syntax.ErrMissingBracket, `[a-z`},
+		{`[z-a]`, syntax.ErrInvalidCharRange, `z-a`},
+		{`*`, syntax.ErrMissingRepeatArgument, `*`},
+		{`a**`, syntax.ErrInvalidRepeatOp, `**`},
+		{`a{2,1}`, syntax.ErrInvalidRepeatSize, `{2,1}`},
+		{`a{1001}`, syntax.ErrInvalidRepeatSize, `{1001}`},
+		{`\x`, syntax.ErrInvalidEscape, `\x`},
+		{`(?P<>a)`, syntax.ErrInvalidNamedCapture, `(?P<>`},
+		{`(?i)(?z)`, syntax.ErrInvalidPerlOp, `(?z`},
+	}
+
+	for _, tc := range tests {
+		_, err := Compile(tc.pattern)
+
+		var reErr *Error
+		if !errors.As(err, &reErr) {
+			t.Errorf("Compile(%#q) returned error %v, want *Error", tc.pattern, err)
+			continue
+		}
+		if reErr.Code != tc.code || reErr.Expr != tc.expr || reErr.Pattern != tc.pattern {
+			t.Errorf("Compile(%#q) returned error {%q, %#q, %#q}, want {%q, %#q, %#q}",
+				tc.pattern, reErr.Code, reErr.Expr, reErr.Pattern, tc.code, tc.expr, tc.pattern)
+		}
+
+		var synErr *syntax.Error
+		if !errors.As(err, &synErr) {
+			t.Errorf("Compile(%#q) returned error %v, want convertible to *syntax.Error", tc.pattern, err)
+			continue
+		}
+		if synErr.Code != tc.code || synErr.Expr != tc.expr {
+			t.Errorf("Compile(%#q) returned syntax error {%q, %#q}, want {%q, %#q}",
+				tc.pattern, synErr.Code, synErr.Expr, tc.code, tc.expr)
+		}
+		if synErr.Error() != err.Error() {
+			t.Errorf("Compile(%#q) returned error %q, want same as syntax error %q", tc.pattern, err, synErr)
+		}
+	}
+}
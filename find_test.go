@@ -2,6 +2,9 @@ package re2
 
 import (
 	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -37,6 +40,7 @@ var findTests = []FindTest{
 	{`^abcd$`, "abcde", nil},
 	{`a+`, "baaab", build(1, 1, 4)},
 	{`a*`, "baaab", build(3, 0, 0, 1, 4, 5, 5)},
+	{`x*`, "日本", build(3, 0, 0, 3, 3, 6, 6)},
 	{`[a-z]+`, "abcd", build(1, 0, 4)},
 	{`[^a-z]+`, "ab1234cd", build(1, 2, 6)},
 	{`[a\-\]z]+`, "az]-bcz", build(2, 0, 4, 6, 7)},
@@ -116,6 +120,7 @@ var findTests = []FindTest{
 	{"[\\`]+", "`", build(1, 0, 1)},
 
 	// GAP - re2 discards non-utf8 bytes in input strings, so they can never be matched.
+	// Bytes can be matched with EncodingLatin1 instead, see TestLatin1.
 	// {"\ufffd", "\xff", build(1, 0, 1)},
 	// {"\ufffd", "hello\xffworld", build(1, 5, 6)},
 	// {`.*`, "hello\xffworld", build(1, 0, 11)},
@@ -504,3 +509,21 @@ func TestFindAllStringSubmatchIndex(t *testing.T) {
 		testFindAllSubmatchIndex(&test, MustCompile(test.pat).FindAllStringSubmatchIndex(test.text, -1), t)
 	}
 }
+
+// TestFindAllBatches checks matches spanning several batches of findAllBatch
+// matches found by re2 in a single call.
+func TestFindAllBatches(t *testing.T) {
+	input := strings.Repeat("ab-", 3*findAllBatch) + "日本語"
+	for _, expr := range []string{`(a)(b)?`, `(x)*`, `a|(b)`} {
+		re := MustCompile(expr)
+		stdRE := regexp.MustCompile(expr)
+		for _, n := range []int{-1, 0, 1, findAllBatch - 1, findAllBatch, findAllBatch + 1, 2 * findAllBatch} {
+			if got, want := re.FindAllStringSubmatchIndex(input, n), stdRE.FindAllStringSubmatchIndex(input, n); !reflect.DeepEqual(got, want) {
+				t.Errorf("%#q.FindAllStringSubmatchIndex(input, %d) = %v, want %v", expr, n, got, want)
+			}
+			if got, want := re.FindAllIndex([]byte(input), n), stdRE.FindAllIndex([]byte(input), n); !reflect.DeepEqual(got, want) {
+				t.Errorf("%#q.FindAllIndex(input, %d) = %v, want %v", expr, n, got, want)
+			}
+		}
+	}
+}
@@ -183,6 +183,23 @@ cre2_program_size (const cre2_regexp_t *re)
 {
   return TO_CONST_RE2(re)->ProgramSize();
 }
+int
+cre2_reverse_program_size (const cre2_regexp_t *re)
+{
+  return TO_CONST_RE2(re)->ReverseProgramSize();
+}
+int
+cre2_program_fanout (const cre2_regexp_t *re, int *histogram, int histogram_len)
+{
+  std::vector<int>	H;
+  int			n;
+  TO_CONST_RE2(re)->ProgramFanout(&H);
+  n = (int)H.size() < histogram_len ? (int)H.size() : histogram_len;
+  for (int i = 0; i < n; ++i) {
+    histogram[i] = H[i];
+  }
+  return n;
+}
 
 
 /** --------------------------------------------------------------------
@@ -265,6 +282,90 @@ cre2_match (const cre2_regexp_t *re , const char *text,
   return (retval)? 1 : 0;
 }
 int
+cre2_match_batch (const cre2_regexp_t *re, const cre2_string_t *texts,
+		  int ntexts, cre2_anchor_t anchor, int *matched,
+		  cre2_string_t *match, int nmatch)
+{
+  int	count = 0;
+  for (int i=0; i<ntexts; i++) {
+    matched[i] = cre2_match(re, texts[i].data, texts[i].length, 0, texts[i].length,
+			    anchor, match + i*nmatch, nmatch);
+    count += matched[i];
+  }
+  return count;
+}
+/* Returns the width of the character at the start of text, counting an
+   invalid UTF-8 sequence as 1 byte like Go's utf8.DecodeRune. */
+static int
+utf8_char_width (const unsigned char *text, int textlen)
+{
+  int		width;
+  unsigned char	lo = 0x80, hi = 0xBF;
+  if (textlen < 1 || text[0] < 0x80) {
+    return 1;
+  } else if (text[0] >= 0xC2 && text[0] <= 0xDF) {
+    width = 2;
+  } else if (text[0] >= 0xE0 && text[0] <= 0xEF) {
+    width = 3;
+    if (text[0] == 0xE0) lo = 0xA0;
+    if (text[0] == 0xED) hi = 0x9F;
+  } else if (text[0] >= 0xF0 && text[0] <= 0xF4) {
+    width = 4;
+    if (text[0] == 0xF0) lo = 0x90;
+    if (text[0] == 0xF4) hi = 0x8F;
+  } else {
+    return 1;
+  }
+  if (textlen < width || text[1] < lo || text[1] > hi) {
+    return 1;
+  }
+  for (int i=2; i<width; i++) {
+    if (text[i] < 0x80 || text[i] > 0xBF) {
+      return 1;
+    }
+  }
+  return width;
+}
+int
+cre2_find_all (const cre2_regexp_t *re, const char *text, int textlen,
+	       int *state, int utf8, cre2_string_t *match, int nmatch, int maxmatches)
+{
+  re2::StringPiece	text_re2(text, textlen);
+  std::vector<re2::StringPiece>	match_re2(nmatch > 0 ? nmatch : 1);
+  int			pos = state[0];
+  int			prev_match_end = state[1];
+  int			count = 0;
+  /* Like regexp, an empty match right after the previous match is skipped,
+     and the search continues after the character following an empty match. */
+  while (count < maxmatches && pos <= textlen) {
+    if (!TO_CONST_RE2(re)->Match(text_re2, pos, textlen, RE2::UNANCHORED,
+				 match_re2.data(), (int)match_re2.size())) {
+      pos = textlen + 1;
+      break;
+    }
+    int	start = match_re2[0].data() - text;
+    int	end   = start + match_re2[0].length();
+    bool	accept = true;
+    if (start == end) {
+      accept = start != prev_match_end;
+      pos = start + (utf8 ? utf8_char_width((const unsigned char *)text + start, textlen - start) : 1);
+    } else {
+      pos = end;
+    }
+    prev_match_end = end;
+    if (accept) {
+      for (int i=0; i<nmatch; i++) {
+	match[count*nmatch+i].data   = match_re2[i].data();
+	match[count*nmatch+i].length = match_re2[i].length();
+      }
+      count++;
+    }
+  }
+  state[0] = pos;
+  state[1] = prev_match_end;
+  return count;
+}
+int
 cre2_easy_match (const char * pattern, int pattern_len,
 		 const char *text, int text_len,
 		 cre2_string_t *match, int nmatch)
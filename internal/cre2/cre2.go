@@ -10,9 +10,15 @@ void cre2_delete(void* re);
 int cre2_error_code(void* re);
 void cre2_error_arg(void* re, void* arg);
 int cre2_match(void* re, void* text, int text_len, int startpos, int endpos, int anchor, void* match_arr, int nmatch);
+int cre2_find_all(void* re, void* text, int text_len, void* state, int utf8, void* match_arr, int nmatch, int maxmatches);
+int cre2_match_batch(void* re, void* texts, int ntexts, int anchor, void* matched, void* match_arr, int nmatch);
 int cre2_find_and_consume_re(void* re, void* text, void* match, int nmatch);
 int cre2_global_replace_re(void* re, void* textAndTarget, void* rewrite);
 int cre2_num_capturing_groups(void* re);
+int cre2_program_size(void* re);
+int cre2_reverse_program_size(void* re);
+int cre2_program_fanout(void* re, void* histogram, int histogram_len);
+int cre2_possible_match_range(void* re, void* min, void* max, int maxlen);
 void* cre2_named_groups_iter_new(void* re);
 bool cre2_named_groups_iter_next(void* iter, void** name, int* index);
 void cre2_named_groups_iter_delete(void* iter);
@@ -23,6 +29,21 @@ void cre2_opt_set_log_errors(void* opt, int flag);
 void cre2_opt_set_longest_match(void* opt, int flag);
 void cre2_opt_set_posix_syntax(void* opt, int flag);
 void cre2_opt_set_case_sensitive(void* opt, int flag);
+void cre2_opt_set_literal(void* opt, int flag);
+void cre2_opt_set_never_nl(void* opt, int flag);
+void cre2_opt_set_dot_nl(void* opt, int flag);
+void cre2_opt_set_never_capture(void* opt, int flag);
+void cre2_opt_set_perl_classes(void* opt, int flag);
+void cre2_opt_set_word_boundary(void* opt, int flag);
+void cre2_opt_set_one_line(void* opt, int flag);
+void cre2_opt_set_max_mem(void* opt, long long m);
+void cre2_opt_set_encoding(void* opt, int enc);
+
+void* cre2_set_new(void* opt, int anchor);
+void cre2_set_delete(void* set);
+int cre2_set_add(void* set, void* pattern, unsigned long pattern_len, void* error, unsigned long error_len);
+int cre2_set_compile(void* set);
+unsigned long cre2_set_match(void* set, void* text, unsigned long text_len, void* match, unsigned long match_len);
 
 void* malloc(unsigned long size);
 void free(void* ptr);
@@ -50,14 +71,22 @@ func FindAndConsume(rePtr unsafe.Pointer, textPtr unsafe.Pointer, matchPtr unsaf
 	return C.cre2_find_and_consume_re(rePtr, textPtr, matchPtr, C.int(nMatch)) > 0
 }
 
-func GlobalReplace(rePtr unsafe.Pointer, textAndTargetPtr unsafe.Pointer, rewritePtr unsafe.Pointer) bool {
-	return C.cre2_global_replace_re(rePtr, textAndTargetPtr, rewritePtr) > 0
+func GlobalReplace(rePtr unsafe.Pointer, textAndTargetPtr unsafe.Pointer, rewritePtr unsafe.Pointer) int {
+	return int(C.cre2_global_replace_re(rePtr, textAndTargetPtr, rewritePtr))
 }
 
 func Match(rePtr unsafe.Pointer, textPtr unsafe.Pointer, textLen int, startPos int, endPos int, anchor int, matchArr unsafe.Pointer, nMatch int) bool {
 	return C.cre2_match(rePtr, textPtr, C.int(textLen), C.int(startPos), C.int(endPos), C.int(anchor), matchArr, C.int(nMatch)) > 0
 }
 
+func FindAll(rePtr unsafe.Pointer, textPtr unsafe.Pointer, textLen int, statePtr unsafe.Pointer, utf8 bool, matchArr unsafe.Pointer, nMatch int, maxMatches int) int {
+	return int(C.cre2_find_all(rePtr, textPtr, C.int(textLen), statePtr, cFlag(utf8), matchArr, C.int(nMatch), C.int(maxMatches)))
+}
+
+func MatchBatch(rePtr unsafe.Pointer, textsPtr unsafe.Pointer, nTexts int, anchor int, matchedPtr unsafe.Pointer, matchArr unsafe.Pointer, nMatch int) int {
+	return int(C.cre2_match_batch(rePtr, textsPtr, C.int(nTexts), C.int(anchor), matchedPtr, matchArr, C.int(nMatch)))
+}
+
 func NamedGroupsIterNew(rePtr unsafe.Pointer) unsafe.Pointer {
 	return C.cre2_named_groups_iter_new(rePtr)
 }
@@ -77,6 +106,22 @@ func NumCapturingGroups(rePtr unsafe.Pointer) int {
 	return int(C.cre2_num_capturing_groups(rePtr))
 }
 
+func ProgramSize(rePtr unsafe.Pointer) int {
+	return int(C.cre2_program_size(rePtr))
+}
+
+func ReverseProgramSize(rePtr unsafe.Pointer) int {
+	return int(C.cre2_reverse_program_size(rePtr))
+}
+
+func ProgramFanout(rePtr unsafe.Pointer, histogramPtr unsafe.Pointer, histogramLen int) int {
+	return int(C.cre2_program_fanout(rePtr, histogramPtr, C.int(histogramLen)))
+}
+
+func PossibleMatchRange(rePtr unsafe.Pointer, minPtr unsafe.Pointer, maxPtr unsafe.Pointer, maxLen int) int {
+	return int(C.cre2_possible_match_range(rePtr, minPtr, maxPtr, C.int(maxLen)))
+}
+
 func NewOpt() unsafe.Pointer {
 	return C.cre2_opt_new()
 }
@@ -101,6 +146,62 @@ func OptSetCaseSensitive(opt unsafe.Pointer, flag bool) {
 	C.cre2_opt_set_case_sensitive(opt, cFlag(flag))
 }
 
+func OptSetLiteral(opt unsafe.Pointer, flag bool) {
+	C.cre2_opt_set_literal(opt, cFlag(flag))
+}
+
+func OptSetNeverNL(opt unsafe.Pointer, flag bool) {
+	C.cre2_opt_set_never_nl(opt, cFlag(flag))
+}
+
+func OptSetDotNL(opt unsafe.Pointer, flag bool) {
+	C.cre2_opt_set_dot_nl(opt, cFlag(flag))
+}
+
+func OptSetNeverCapture(opt unsafe.Pointer, flag bool) {
+	C.cre2_opt_set_never_capture(opt, cFlag(flag))
+}
+
+func OptSetPerlClasses(opt unsafe.Pointer, flag bool) {
+	C.cre2_opt_set_perl_classes(opt, cFlag(flag))
+}
+
+func OptSetWordBoundary(opt unsafe.Pointer, flag bool) {
+	C.cre2_opt_set_word_boundary(opt, cFlag(flag))
+}
+
+func OptSetOneLine(opt unsafe.Pointer, flag bool) {
+	C.cre2_opt_set_one_line(opt, cFlag(flag))
+}
+
+func OptSetMaxMem(opt unsafe.Pointer, maxMem int64) {
+	C.cre2_opt_set_max_mem(opt, C.longlong(maxMem))
+}
+
+func OptSetEncoding(opt unsafe.Pointer, encoding int) {
+	C.cre2_opt_set_encoding(opt, C.int(encoding))
+}
+
+func NewSet(opt unsafe.Pointer, anchor int) unsafe.Pointer {
+	return C.cre2_set_new(opt, C.int(anchor))
+}
+
+func DeleteSet(setPtr unsafe.Pointer) {
+	C.cre2_set_delete(setPtr)
+}
+
+func SetAdd(setPtr unsafe.Pointer, patternPtr unsafe.Pointer, patternLen int, errorPtr unsafe.Pointer, errorLen int) int {
+	return int(C.cre2_set_add(setPtr, patternPtr, C.ulong(patternLen), errorPtr, C.ulong(errorLen)))
+}
+
+func SetCompile(setPtr unsafe.Pointer) bool {
+	return C.cre2_set_compile(setPtr) > 0
+}
+
+func SetMatch(setPtr unsafe.Pointer, textPtr unsafe.Pointer, textLen int, matchPtr unsafe.Pointer, matchLen int) int {
+	return int(C.cre2_set_match(setPtr, textPtr, C.ulong(textLen), matchPtr, C.ulong(matchLen)))
+}
+
 func Malloc(size int) unsafe.Pointer {
 	return C.malloc(C.ulong(size))
 }
@@ -120,6 +120,8 @@ cre2_decl const char * cre2_pattern	(const cre2_regexp_t *re);
 cre2_decl int cre2_error_code		(const cre2_regexp_t *re);
 cre2_decl int cre2_num_capturing_groups	(const cre2_regexp_t *re);
 cre2_decl int cre2_program_size		(const cre2_regexp_t *re);
+cre2_decl int cre2_reverse_program_size	(const cre2_regexp_t *re);
+cre2_decl int cre2_program_fanout	(const cre2_regexp_t *re, int *histogram, int histogram_len);
 
 /* named capture information */
 cre2_decl int cre2_find_named_capturing_groups  (const cre2_regexp_t *re, const char *name);
@@ -152,6 +154,15 @@ cre2_decl int cre2_match	(const cre2_regexp_t * re,
 				 int startpos, int endpos, cre2_anchor_t anchor,
 				 cre2_string_t * match, int nmatch);
 
+cre2_decl int cre2_match_batch	(const cre2_regexp_t * re,
+				 const cre2_string_t * texts, int ntexts,
+				 cre2_anchor_t anchor, int * matched,
+				 cre2_string_t * match, int nmatch);
+
+cre2_decl int cre2_find_all	(const cre2_regexp_t * re,
+				 const char * text, int textlen, int * state, int utf8,
+				 cre2_string_t * match, int nmatch, int maxmatches);
+
 cre2_decl int cre2_easy_match	(const char * pattern, int pattern_len,
 				 const char * text, int text_len,
 				 cre2_string_t * match, int nmatch);
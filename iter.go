@@ -0,0 +1,133 @@
+//go:build go1.23
+
+package re2
+
+import (
+	"iter"
+	"runtime"
+	"sync/atomic"
+)
+
+// iterBatchMax is the most matches an iterator finds in a single operation.
+// Iterators start with findAllBatch matches and double the batch each time,
+// so that few operations are needed for inputs with many matches.
+const iterBatchMax = 64 * findAllBatch
+
+// AllMatches returns an iterator over the index pairs of all successive
+// matches of the expression in s, as returned by FindAllStringIndex(s, -1).
+// Matches are found lazily in batches, each in a separate operation, so the
+// Regexp is not held while the loop body runs and may be used within it.
+// s is copied once for the whole loop, which keeps the copy until it ends.
+// Breaking out of the loop stops finding matches.
+func (re *Regexp) AllMatches(s string) iter.Seq[[]int] {
+	return re.allMatches(s, 1)
+}
+
+// AllSubmatches returns an iterator over the index pairs of all successive
+// matches of the expression in s and of its subexpressions, as returned by
+// FindAllStringSubmatchIndex(s, -1). Like AllMatches, matches are found lazily
+// in batches.
+func (re *Regexp) AllSubmatches(s string) iter.Seq[[]int] {
+	return re.allMatches(s, re.numMatches)
+}
+
+// SplitSeq returns an iterator over the substrings of s between the matches
+// of the expression, as returned by Split(s, -1). Like AllMatches, matches are
+// found lazily in batches.
+func (re *Regexp) SplitSeq(s string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		// Same logic as Split.
+		if len(re.expr) > 0 && len(s) == 0 {
+			yield("")
+			return
+		}
+
+		beg := 0
+		end := 0
+		for match := range re.AllMatches(s) {
+			end = match[0]
+			if match[1] != 0 {
+				if !yield(s[beg:end]) {
+					return
+				}
+			}
+			beg = match[1]
+		}
+
+		if end != len(s) {
+			yield(s[beg:])
+		}
+	}
+}
+
+func (re *Regexp) allMatches(s string, numGroups int) iter.Seq[[]int] {
+	return func(yield func([]int) bool) {
+		// s is copied once for all batches rather than for each of them.
+		text := newResidentString(re, s)
+		defer func() {
+			text.release()
+		}()
+
+		pos, prevMatchEnd := 0, -1
+		batch := findAllBatch
+		for pos <= len(s) {
+			matches, ok := re.findBatch(text, &pos, &prevMatchEnd, numGroups, batch)
+			if !ok {
+				// The module holding the copy became unusable in another
+				// operation, so the search continues from a new copy.
+				text = newResidentString(re, s)
+				continue
+			}
+			for _, match := range matches {
+				if !yield(match) {
+					return
+				}
+			}
+			if batch < iterBatchMax {
+				batch *= 2
+			}
+		}
+	}
+}
+
+// findBatch finds up to batch successive matches in text, continuing a search
+// at pos after a match ending at prevMatchEnd, and updates them to continue
+// the search from. The position is past the end of text once there are no
+// more matches. ok is false if text must be copied again to search it.
+func (re *Regexp) findBatch(text *residentString, pos *int, prevMatchEnd *int, numGroups int, batch int) (matches [][]int, ok bool) {
+	if atomic.LoadUint32(&re.released) == 1 {
+		panic(errClosed)
+	}
+	inst, ok := text.startOperation(re, 8*numGroups*batch+8)
+	if !ok {
+		return nil, false
+	}
+	defer inst.abi.endOperation()
+
+	cs := text.cs
+	matchArr := newCStringArray(inst.abi, numGroups*batch)
+	state := newFindAllState(inst.abi, *pos, *prevMatchEnd)
+
+	found := findAllFrom(inst, cs, state, re.opts.Encoding != EncodingLatin1, matchArr.ptr, numGroups, batch)
+
+	matches = make([][]int, 0, found)
+	var match []int
+	readMatches(inst.abi, cs, matchArr.ptr, found*numGroups, func(group []int) {
+		if match == nil {
+			match = make([]int, 0, 2*numGroups)
+		}
+		match = append(match, group...)
+		if len(match) == cap(match) {
+			matches = append(matches, match)
+			match = nil
+		}
+	})
+	runtime.KeepAlive(text)
+
+	if found < batch {
+		*pos, *prevMatchEnd = cs.length+1, -1
+	} else {
+		*pos, *prevMatchEnd = state.read(inst.abi)
+	}
+	return matches, true
+}
@@ -0,0 +1,87 @@
+//go:build go1.23
+
+package re2
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAllMatches(t *testing.T) {
+	tests := []struct {
+		expr  string
+		input string
+	}{
+		{`a+`, ""},
+		{`a+`, "banana"},
+		{`x*`, "日本語"},
+		{`\bw`, "a word, w w"},
+		{`(?m)^(\w)(x)?`, "ab\ncd\nxe"},
+		{`(a)|(b)`, strings.Repeat("ab-", 3*iterBatchMax/2)},
+		{``, strings.Repeat("é", 5*findAllBatch)},
+		{`(?m)^\w`, strings.Repeat("ab\n", 3*findAllBatch)},
+		{`\b\w|x*`, strings.Repeat("ab cd", 3*findAllBatch)},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.expr, func(t *testing.T) {
+			re := MustCompile(tt.expr)
+			stdRE := regexp.MustCompile(tt.expr)
+
+			var matches [][]int
+			for match := range re.AllMatches(tt.input) {
+				matches = append(matches, match)
+			}
+			if want := stdRE.FindAllStringIndex(tt.input, -1); !reflect.DeepEqual(matches, want) {
+				t.Errorf("AllMatches(%q) = %v, want %v", tt.input, matches, want)
+			}
+
+			var submatches [][]int
+			for match := range re.AllSubmatches(tt.input) {
+				submatches = append(submatches, match)
+			}
+			if want := stdRE.FindAllStringSubmatchIndex(tt.input, -1); !reflect.DeepEqual(submatches, want) {
+				t.Errorf("AllSubmatches(%q) = %v, want %v", tt.input, submatches, want)
+			}
+
+			split := []string{}
+			for s := range re.SplitSeq(tt.input) {
+				split = append(split, s)
+			}
+			if want := stdRE.Split(tt.input, -1); !reflect.DeepEqual(split, want) {
+				t.Errorf("SplitSeq(%q) = %q, want %q", tt.input, split, want)
+			}
+		})
+	}
+}
+
+func TestAllMatchesBreak(t *testing.T) {
+	re := MustCompile(`\d+`)
+	input := strings.Repeat("1 22 333 ", 1000)
+
+	var matches []string
+	for match := range re.AllMatches(input) {
+		// The Regexp is not held while the loop body runs.
+		if !re.MatchString(input[match[0]:match[1]]) {
+			t.Fatalf("MatchString(%q) = false", input[match[0]:match[1]])
+		}
+		matches = append(matches, input[match[0]:match[1]])
+		if len(matches) == 3 {
+			break
+		}
+	}
+	if want := []string{"1", "22", "333"}; !reflect.DeepEqual(matches, want) {
+		t.Errorf("AllMatches(input) = %q, want %q", matches, want)
+	}
+
+	for s := range re.SplitSeq(input) {
+		// input starts with a match.
+		if s != "" {
+			t.Errorf("SplitSeq(input) = %q, want \"\"", s)
+		}
+		break
+	}
+}
@@ -0,0 +1,34 @@
+//go:build go1.23 && !tinygo.wasm && !re2_cgo
+
+package re2
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAllMatchesBrokenModule(t *testing.T) {
+	re := MustCompile(`\d+`)
+	defer re.Close()
+	input := strings.Repeat("1 22 333 ", 3*findAllBatch)
+
+	count := 0
+	for match := range re.AllMatches(input) {
+		if count == 0 {
+			// The module holding the copy of input becomes unusable between
+			// batches, as after running out of memory in another operation.
+			abi := re.abi
+			abi.mu.Lock()
+			_ = abi.callError(errors.New("broken"))
+			abi.mu.Unlock()
+		}
+		if want := []string{"1", "22", "333"}[count%3]; input[match[0]:match[1]] != want {
+			t.Fatalf("match %d = %q, want %q", count, input[match[0]:match[1]], want)
+		}
+		count++
+	}
+	if want := 3 * 3 * findAllBatch; count != want {
+		t.Errorf("found %d matches, want %d", count, want)
+	}
+}
@@ -0,0 +1,143 @@
+package re2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// optionsPrefix starts text marshaled from a Regexp compiled with options. It
+// is not a valid expression, so it is never confused with one.
+const optionsPrefix = "(?re2:"
+
+// MarshalText implements encoding.TextMarshaler. The output matches the
+// result of calling the String method for a Regexp compiled with Compile, the
+// same as the standard library. Otherwise, its options, including those set
+// by CompilePOSIX and Longest, are written before the expression as in
+// (?re2:POSIXSyntax,Longest)expr so that UnmarshalText restores them.
+func (re *Regexp) MarshalText() ([]byte, error) {
+	opts := marshalOptions(re.opts)
+	if opts == "" {
+		return []byte(re.expr), nil
+	}
+	return []byte(optionsPrefix + opts + ")" + re.expr), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler by calling
+// CompileWithOptions on the encoded value, with the options written by
+// MarshalText if any. re can be a field or element of type Regexp, such as
+// one decoded by encoding/json, and any expression it held is closed.
+func (re *Regexp) UnmarshalText(text []byte) error {
+	expr, opts, err := unmarshalOptions(string(text))
+	if err != nil {
+		return err
+	}
+
+	newRE, err := compile(expr, opts)
+	if err != nil {
+		return err
+	}
+
+	if re.handle != nil {
+		re.Close()
+	}
+	// The finalizer set by compile is on the handle, which re now keeps.
+	re.handle = newRE.handle
+	re.opts = newRE.opts
+	re.expr = newRE.expr
+	re.numMatches = newRE.numMatches
+	re.groupNames = nil
+	re.groupNamesOnce = sync.Once{}
+
+	return nil
+}
+
+// marshalOptions returns the names of the options set in opts separated by
+// commas.
+func marshalOptions(opts Options) string {
+	var names []string
+	for _, o := range boolOptions {
+		if *o.field(&opts) {
+			names = append(names, o.name)
+		}
+	}
+	if opts.MaxMem != 0 {
+		names = append(names, "MaxMem="+strconv.FormatInt(opts.MaxMem, 10))
+	}
+	if opts.Encoding == EncodingLatin1 {
+		names = append(names, "Encoding=Latin1")
+	}
+	return strings.Join(names, ",")
+}
+
+// unmarshalOptions splits text marshaled from a Regexp into its expression and
+// options.
+func unmarshalOptions(text string) (string, Options, error) {
+	var opts Options
+	if !strings.HasPrefix(text, optionsPrefix) {
+		return text, opts, nil
+	}
+
+	end := strings.IndexByte(text, ')')
+	if end < 0 {
+		return "", opts, fmt.Errorf("re2: missing ) after options in %q", text)
+	}
+
+	for _, name := range strings.Split(text[len(optionsPrefix):end], ",") {
+		if name == "" {
+			continue
+		}
+		if v, ok := cutPrefix(name, "MaxMem="); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return "", opts, fmt.Errorf("re2: invalid MaxMem %q in %q", v, text)
+			}
+			opts.MaxMem = n
+			continue
+		}
+		if name == "Encoding=Latin1" {
+			opts.Encoding = EncodingLatin1
+			continue
+		}
+		found := false
+		for _, o := range boolOptions {
+			if o.name == name {
+				*o.field(&opts) = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", opts, fmt.Errorf("re2: unknown option %q in %q", name, text)
+		}
+	}
+
+	return text[end+1:], opts, nil
+}
+
+// boolOptions are the boolean fields of Options by name, in the order they are
+// marshaled.
+var boolOptions = []struct {
+	name  string
+	field func(opts *Options) *bool
+}{
+	{"POSIXSyntax", func(opts *Options) *bool { return &opts.POSIXSyntax }},
+	{"Longest", func(opts *Options) *bool { return &opts.Longest }},
+	{"Literal", func(opts *Options) *bool { return &opts.Literal }},
+	{"NeverNL", func(opts *Options) *bool { return &opts.NeverNL }},
+	{"DotNL", func(opts *Options) *bool { return &opts.DotNL }},
+	{"NeverCapture", func(opts *Options) *bool { return &opts.NeverCapture }},
+	{"CaseInsensitive", func(opts *Options) *bool { return &opts.CaseInsensitive }},
+	{"PerlClasses", func(opts *Options) *bool { return &opts.PerlClasses }},
+	{"WordBoundary", func(opts *Options) *bool { return &opts.WordBoundary }},
+	{"OneLine", func(opts *Options) *bool { return &opts.OneLine }},
+}
+
+// cutPrefix is strings.CutPrefix, which is not available before Go 1.20.
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
@@ -0,0 +1,139 @@
+package re2
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"runtime"
+	"testing"
+)
+
+func TestMarshalText(t *testing.T) {
+	tests := []struct {
+		name string
+		re   func() *Regexp
+		text string
+	}{
+		{"Compile", func() *Regexp { return MustCompile(`a+(b)`) }, `a+(b)`},
+		{"CompilePOSIX", func() *Regexp { return MustCompilePOSIX(`a+|ab`) }, `(?re2:POSIXSyntax,Longest)a+|ab`},
+		{"Longest", func() *Regexp {
+			re := MustCompile(`a+?`)
+			re.Longest()
+			return re
+		}, `(?re2:Longest)a+?`},
+		{"options", func() *Regexp {
+			return MustCompileWithOptions(`a.c`, Options{CaseInsensitive: true, DotNL: true, MaxMem: 1 << 20, Encoding: EncodingLatin1})
+		}, `(?re2:DotNL,CaseInsensitive,MaxMem=1048576,Encoding=Latin1)a.c`},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			re := tt.re()
+			text, err := re.MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(text) != tt.text {
+				t.Errorf("MarshalText() = %q, want %q", text, tt.text)
+			}
+
+			var got Regexp
+			if err := got.UnmarshalText(text); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != re.String() || got.opts != re.opts {
+				t.Errorf("UnmarshalText(%q) = %q with %+v, want %q with %+v", text, got.String(), got.opts, re.String(), re.opts)
+			}
+			for _, s := range []string{"xaab", "AB", "a\nc", "aaa"} {
+				if g, w := got.FindStringIndex(s), re.FindStringIndex(s); !reflect.DeepEqual(g, w) {
+					t.Errorf("FindStringIndex(%q) after round trip = %v, want %v", s, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalOptions(t *testing.T) {
+	opts := Options{MaxMem: 1 << 20, Encoding: EncodingLatin1}
+	for _, o := range boolOptions {
+		*o.field(&opts) = true
+	}
+
+	text := optionsPrefix + marshalOptions(opts) + ")a(b)"
+	expr, got, err := unmarshalOptions(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr != "a(b)" || !reflect.DeepEqual(got, opts) {
+		t.Errorf("unmarshalOptions(%q) = (%q, %+v), want (%q, %+v)", text, expr, got, "a(b)", opts)
+	}
+}
+
+func TestUnmarshalTextError(t *testing.T) {
+	tests := []string{
+		`(?re2:Unknown)a`,
+		`(?re2:MaxMem=x)a`,
+		`(?re2:Longest`,
+		`a(`,
+	}
+
+	for _, text := range tests {
+		var re Regexp
+		if err := re.UnmarshalText([]byte(text)); err == nil {
+			t.Errorf("UnmarshalText(%q) returned no error", text)
+		}
+	}
+}
+
+func TestUnmarshalJSONValueField(t *testing.T) {
+	var cfg struct {
+		Name string
+		Pat  Regexp
+	}
+	if err := json.Unmarshal([]byte(`{"Name":"a","Pat":"a+(b)"}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	// Unmarshaling again closes the previous expression.
+	if err := json.Unmarshal([]byte(`{"Name":"b","Pat":"(?re2:Longest)a+?(b)"}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	runtime.GC()
+	if got, want := cfg.Pat.FindStringSubmatch("xaab"), []string{"aab", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindStringSubmatch(%q) = %q, want %q", "xaab", got, want)
+	}
+	cfg.Pat.Close()
+}
+
+func TestMarshalJSON(t *testing.T) {
+	type config struct {
+		Pattern *Regexp
+	}
+
+	in := config{Pattern: MustCompilePOSIX(`a+|ab`)}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out config
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.Pattern.FindString("xab"); got != "ab" {
+		t.Errorf("FindString(%q) = %q, want %q", "xab", got, "ab")
+	}
+
+	// Patterns compiled with Compile are encoded the same as the standard library.
+	std, err := json.Marshal(struct{ Pattern *regexp.Regexp }{regexp.MustCompile(`a+(b)`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = json.Marshal(config{Pattern: MustCompile(`a+(b)`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(std) {
+		t.Errorf("json.Marshal = %s, want %s", b, std)
+	}
+}
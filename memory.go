@@ -0,0 +1,52 @@
+package re2
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrMemoryLimit is reported when re2 cannot allocate memory for an operation
+// in the default WebAssembly mode, because the module instance running it
+// has reached the limit set with SetMemoryLimit or the 4GB WebAssembly
+// maximum. Methods that return an error, such as Compile and the Context
+// methods, return an error wrapping it. Other methods panic with such an
+// error, which can be recovered and checked with errors.Is.
+var ErrMemoryLimit = errors.New("re2: memory limit exceeded")
+
+var memoryLimit int64
+
+// SetMemoryLimit sets the maximum size in bytes of the memory of each
+// WebAssembly module instance in the default WebAssembly mode, so that large
+// inputs or expressions cause ErrMemoryLimit instead of growing memory
+// further. At most SetMaxConcurrency instances are used at a time, which
+// bounds the total memory used by re2. If bytes is not positive, memory is
+// only limited by the 4GB WebAssembly maximum, the default.
+//
+// Only methods with an error result, such as Compile, Set.Add and the
+// Context methods like MatchStringContext and ReplaceAllStringContext,
+// return ErrMemoryLimit. Methods without one, such as MatchString,
+// FindString and ReplaceAllString, still panic with an error wrapping it, so
+// code matching untrusted input under a limit should use the Context
+// methods or recover the panic.
+//
+// The limit applies to module instances created after the call, so it should
+// be set before compiling any expression. With cgo or TinyGo, memory is
+// allocated natively and this has no effect.
+func SetMemoryLimit(bytes int64) {
+	atomic.StoreInt64(&memoryLimit, bytes)
+	memoryLimitChanged()
+}
+
+// recoverMemoryLimit is deferred by methods that return an error to return a
+// panic with ErrMemoryLimit as their error instead.
+func recoverMemoryLimit(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if e, ok := r.(error); ok && errors.Is(e, ErrMemoryLimit) {
+		*err = e
+		return
+	}
+	panic(r)
+}
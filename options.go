@@ -0,0 +1,96 @@
+package re2
+
+// Encoding is the text encoding used to interpret both a regular expression
+// and the text it is matched against.
+type Encoding int
+
+const (
+	// EncodingUTF8 interprets text as UTF-8. It is the default, and as with
+	// the standard library, characters in the text are runes.
+	EncodingUTF8 Encoding = iota
+
+	// EncodingLatin1 interprets text as Latin-1 (ISO-8859-1), where every byte
+	// is a character. This allows matching arbitrary binary data, such as the
+	// expression `\x00\xff` matching exactly those two bytes. The expression
+	// itself is also interpreted as Latin-1, so characters outside of ASCII
+	// should be written with escapes like \xff rather than literally.
+	EncodingLatin1
+)
+
+// cre2 returns the value of cre2_encoding_t corresponding to the Encoding.
+func (e Encoding) cre2() int {
+	return int(e) + 1
+}
+
+// Options configures the compilation of a regular expression with
+// CompileWithOptions. The zero value compiles an expression the same
+// as Compile.
+type Options struct {
+	// POSIXSyntax restricts the regular expression to POSIX ERE (egrep)
+	// syntax.
+	POSIXSyntax bool
+
+	// Longest makes searches prefer the leftmost-longest match, as if
+	// Longest had been called on the compiled Regexp.
+	Longest bool
+
+	// Literal interprets the expression as a literal string rather than a
+	// regular expression, as if it had been passed through QuoteMeta.
+	Literal bool
+
+	// NeverNL prevents matching a newline, even if it is present in the
+	// expression.
+	NeverNL bool
+
+	// DotNL allows . to match a newline, as if the s flag were set.
+	DotNL bool
+
+	// NeverCapture parses all parentheses as non-capturing, including named
+	// groups, which NumSubexp, SubexpNames and SubexpIndex then ignore.
+	NeverCapture bool
+
+	// CaseInsensitive matches letters in either case, as if the i flag
+	// were set.
+	CaseInsensitive bool
+
+	// PerlClasses allows the Perl classes \d \s \w \D \S \W. It only
+	// has an effect with POSIXSyntax, otherwise they are always allowed.
+	PerlClasses bool
+
+	// WordBoundary allows the assertions \b and \B. It only has an effect
+	// with POSIXSyntax, otherwise they are always allowed.
+	WordBoundary bool
+
+	// OneLine makes ^ and $ only match at the beginning and end of the text.
+	// It only has an effect with POSIXSyntax, otherwise this is always the
+	// behavior unless the m flag is set.
+	OneLine bool
+
+	// MaxMem is the approximate number of bytes of memory the compiled
+	// expression may use, covering both the compiled program and the cache
+	// used while matching. Expressions that do not fit when compiling fail
+	// with an error. If zero, re2's default of 8MB is used.
+	MaxMem int64
+
+	// Encoding is the text encoding of the expression and the text it is
+	// matched against.
+	Encoding Encoding
+}
+
+// CompileWithOptions is like Compile but allows configuring the parsing and
+// matching of the regular expression with opts.
+func CompileWithOptions(expr string, opts Options) (*Regexp, error) {
+	return compile(expr, opts)
+}
+
+// MustCompileWithOptions is like CompileWithOptions but panics if the
+// expression cannot be parsed.
+// It simplifies safe initialization of global variables holding compiled regular
+// expressions.
+func MustCompileWithOptions(str string, opts Options) *Regexp {
+	re, err := CompileWithOptions(str, opts)
+	if err != nil {
+		panic(`regexp: CompileWithOptions(` + quote(str) + `): ` + err.Error())
+	}
+	return re
+}
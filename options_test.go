@@ -0,0 +1,137 @@
+package re2
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type optionsTest struct {
+	expr  string
+	opts  Options
+	text  string
+	match bool
+}
+
+var optionsTests = []optionsTest{
+	{`abc`, Options{}, "xABCx", false},
+	{`abc`, Options{CaseInsensitive: true}, "xABCx", true},
+	{`a.c`, Options{}, "abc", true},
+	{`a.c`, Options{Literal: true}, "abc", false},
+	{`a.c`, Options{Literal: true}, "xa.cx", true},
+	{`a\nb`, Options{}, "a\nb", true},
+	{`a\nb`, Options{NeverNL: true}, "a\nb", false},
+	{`a.c`, Options{}, "a\nc", false},
+	{`a.c`, Options{DotNL: true}, "a\nc", true},
+	{`\d+`, Options{POSIXSyntax: true, PerlClasses: true}, "x123x", true},
+	{`\bfoo\b`, Options{POSIXSyntax: true, WordBoundary: true}, "a foo b", true},
+	{`\bfoo\b`, Options{POSIXSyntax: true, WordBoundary: true}, "afoob", false},
+	{`^b`, Options{POSIXSyntax: true}, "a\nb", true},
+	{`^b`, Options{POSIXSyntax: true, OneLine: true}, "a\nb", false},
+}
+
+func TestCompileWithOptions(t *testing.T) {
+	for _, tc := range optionsTests {
+		tt := tc
+		t.Run(tt.expr, func(t *testing.T) {
+			re, err := CompileWithOptions(tt.expr, tt.opts)
+			if err != nil {
+				t.Fatalf("CompileWithOptions(%#q, %+v) unexpected error: %v", tt.expr, tt.opts, err)
+			}
+			if m := re.MatchString(tt.text); m != tt.match {
+				t.Errorf("CompileWithOptions(%#q, %+v).MatchString(%q) = %v; want %v", tt.expr, tt.opts, tt.text, m, tt.match)
+			}
+			if m := re.Copy().MatchString(tt.text); m != tt.match {
+				t.Errorf("Copy of CompileWithOptions(%#q, %+v).MatchString(%q) = %v; want %v", tt.expr, tt.opts, tt.text, m, tt.match)
+			}
+		})
+	}
+}
+
+func TestCompileWithOptionsPOSIXClasses(t *testing.T) {
+	if _, err := CompileWithOptions(`\d+`, Options{POSIXSyntax: true}); err == nil {
+		t.Errorf("CompileWithOptions(`\\d+`) with POSIX syntax: missing error")
+	}
+}
+
+func TestCompileWithOptionsNeverCapture(t *testing.T) {
+	for _, expr := range []string{`(a)(b)`, `(?P<x>a)(b)`, `(?P<x>a)(?P<y>b)`} {
+		re := MustCompileWithOptions(expr, Options{NeverCapture: true})
+		if n := re.NumSubexp(); n != 0 {
+			t.Errorf("%#q.NumSubexp() = %d; want 0", expr, n)
+		}
+		if names := re.SubexpNames(); len(names) != 1 || names[0] != "" {
+			t.Errorf("%#q.SubexpNames() = %q; want [\"\"]", expr, names)
+		}
+		if i := re.SubexpIndex("x"); i != -1 {
+			t.Errorf("%#q.SubexpIndex(\"x\") = %d; want -1", expr, i)
+		}
+		if m := re.FindStringSubmatch("ab"); len(m) != 1 || m[0] != "ab" {
+			t.Errorf("%#q.FindStringSubmatch(%q) = %q; want [\"ab\"]", expr, "ab", m)
+		}
+		if got := re.ReplaceAllString("ab", "<${x}>"); got != "<>" {
+			t.Errorf("%#q.ReplaceAllString(%q) = %q; want \"<>\"", expr, "ab", got)
+		}
+	}
+}
+
+func TestCompileWithOptionsMaxMem(t *testing.T) {
+	expr := `\pL{100}`
+	if _, err := CompileWithOptions(expr, Options{}); err != nil {
+		t.Fatalf("CompileWithOptions(%#q) unexpected error: %v", expr, err)
+	}
+	if _, err := CompileWithOptions(expr, Options{MaxMem: 1024}); err == nil {
+		t.Errorf("CompileWithOptions(%#q) with MaxMem: missing error", expr)
+	}
+}
+
+func TestLongestKeepsOptions(t *testing.T) {
+	re := MustCompileWithOptions(`a+?`, Options{CaseInsensitive: true})
+	if got := re.FindString("AAA"); got != "A" {
+		t.Errorf("FindString(%q) = %q; want %q", "AAA", got, "A")
+	}
+	re.Longest()
+	if got := re.FindString("AAA"); got != "AAA" {
+		t.Errorf("after Longest, FindString(%q) = %q; want %q", "AAA", got, "AAA")
+	}
+}
+
+func TestCompileWithOptionsUnknownEncoding(t *testing.T) {
+	for _, enc := range []Encoding{-1, EncodingLatin1 + 1} {
+		if _, err := CompileWithOptions(`a`, Options{Encoding: enc}); err == nil {
+			t.Errorf("CompileWithOptions with Encoding %d: missing error", enc)
+		}
+	}
+	// The module used to compile is still usable.
+	if !MustCompile(`a`).MatchString("a") {
+		t.Errorf("MatchString after unknown encoding = false; want true")
+	}
+}
+
+func TestLatin1(t *testing.T) {
+	re := MustCompileWithOptions(`\x00\xff`, Options{Encoding: EncodingLatin1})
+	b := []byte{'a', 0x00, 0xff, 'b', 0x00, 0xff}
+	if !re.Match(b) {
+		t.Errorf("Match(%q) = false; want true", b)
+	}
+	if got, want := re.FindIndex(b), []int{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindIndex(%q) = %v; want %v", b, got, want)
+	}
+	if got, want := re.FindAllIndex(b, -1), [][]int{{1, 3}, {4, 6}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllIndex(%q) = %v; want %v", b, got, want)
+	}
+	if got, want := re.ReplaceAll(b, []byte("-")), []byte("a-b-"); !bytes.Equal(got, want) {
+		t.Errorf("ReplaceAll(%q) = %q; want %q", b, got, want)
+	}
+
+	re = MustCompileWithOptions(`.`, Options{Encoding: EncodingLatin1})
+	b = []byte("\xff\xfe日")
+	if got, want := re.FindAll(b, -1), [][]byte{{0xff}, {0xfe}, {0xe6}, {0x97}, {0xa5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAll(%q) = %q; want %q", b, got, want)
+	}
+
+	re = MustCompileWithOptions(`[\x80-\xff]+`, Options{Encoding: EncodingLatin1})
+	if got, want := re.FindString("hello\xff\xc2world"), "\xff\xc2"; got != want {
+		t.Errorf("FindString(%q) = %q; want %q", "hello\xff\xc2world", got, want)
+	}
+}
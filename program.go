@@ -0,0 +1,41 @@
+package re2
+
+// fanoutBuckets is the number of buckets of a fanout histogram, enough for
+// any fanout of a program as fanouts are bucketed by powers of 2.
+const fanoutBuckets = 32
+
+// ProgramSize returns the number of instructions in the program re2 compiled
+// the expression into, a rough measure of how expensive it is to match.
+// Larger expressions, and in particular counted repetitions, compile into
+// larger programs. Expressions whose program does not fit in MaxMem fail to
+// compile.
+func (re *Regexp) ProgramSize() int {
+	inst := re.startOperation(0)
+	defer inst.abi.endOperation()
+
+	return programSize(inst)
+}
+
+// ReverseProgramSize is like ProgramSize for the reversed program re2 uses to
+// find where matches begin, compiling it if it has not been needed yet. It
+// returns -1 if the reversed program does not fit in MaxMem.
+func (re *Regexp) ReverseProgramSize() int {
+	inst := re.startOperation(0)
+	defer inst.abi.endOperation()
+
+	return reverseProgramSize(inst)
+}
+
+// ProgramFanout returns a histogram of the fanout of the instructions of the
+// program re2 compiled the expression into, which is the number of
+// instructions each can lead to. Element i of the histogram counts the
+// instructions with a fanout greater than 2^(i-1) and at most 2^i, and the
+// histogram ends with the last nonzero element. A large fanout, such as from
+// a large character class in a counted repetition, makes matching expensive
+// even for a small program.
+func (re *Regexp) ProgramFanout() []int {
+	inst := re.startOperation(4 * fanoutBuckets)
+	defer inst.abi.endOperation()
+
+	return programFanout(inst)
+}
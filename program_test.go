@@ -0,0 +1,43 @@
+package re2
+
+import "testing"
+
+func TestProgramSize(t *testing.T) {
+	// Each expression is more complex than the previous one.
+	exprs := []string{`a`, `abc`, `a{10}`, `a{100}`, `(a{100}){10}`}
+
+	prevSize, prevReverse := 0, 0
+	for _, expr := range exprs {
+		re := MustCompile(expr)
+
+		size := re.ProgramSize()
+		if size <= prevSize {
+			t.Errorf("%#q.ProgramSize() = %d, want more than %d", expr, size, prevSize)
+		}
+		prevSize = size
+
+		reverse := re.ReverseProgramSize()
+		if reverse <= prevReverse {
+			t.Errorf("%#q.ReverseProgramSize() = %d, want more than %d", expr, reverse, prevReverse)
+		}
+		prevReverse = reverse
+	}
+}
+
+func TestProgramFanout(t *testing.T) {
+	// The start of each expression can continue with more bytes than the
+	// previous one, 1, 3 and 9, landing in higher buckets.
+	exprs := []string{`a`, `ab|cd|ef`, `ab|cd|ef|gh|ij|kl|mn|op|qr`}
+
+	prevLen := 0
+	for _, expr := range exprs {
+		fanout := MustCompile(expr).ProgramFanout()
+		if len(fanout) <= prevLen {
+			t.Errorf("%#q.ProgramFanout() = %v, want more than %d buckets", expr, fanout, prevLen)
+		}
+		if n := len(fanout); n > 0 && fanout[n-1] == 0 {
+			t.Errorf("%#q.ProgramFanout() = %v, want nonzero last bucket", expr, fanout)
+		}
+		prevLen = len(fanout)
+	}
+}
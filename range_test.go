@@ -0,0 +1,32 @@
+package re2
+
+import "testing"
+
+func TestPossibleMatchRange(t *testing.T) {
+	tests := []struct {
+		pat      string
+		maxLen   int
+		min, max string
+		ok       bool
+	}{
+		{`a+hello`, 10, "aa", "ahello", true},
+		{`a*hello`, 10, "a", "hello", true},
+		{`def|abc`, 10, "abc", "def", true},
+		{`a(b)(c)[d]`, 10, "abcd", "abcd", true},
+		{`(?i)abc`, 10, "ABC", "abc", true},
+		{`(abc)+`, 10, "abc", "abcac", true},
+		{`(abc)+`, 2, "ab", "ac", true},
+		{`abc`, 0, "", "", false},
+		// Any byte can follow, so there is no upper bound.
+		{`\C*`, 10, "", "", false},
+	}
+
+	for _, tc := range tests {
+		re := MustCompile(tc.pat)
+		min, max, ok := re.PossibleMatchRange(tc.maxLen)
+		if min != tc.min || max != tc.max || ok != tc.ok {
+			t.Errorf("%q.PossibleMatchRange(%d) = (%q, %q, %v), want (%q, %q, %v)",
+				tc.pat, tc.maxLen, min, max, ok, tc.min, tc.max, tc.ok)
+		}
+	}
+}
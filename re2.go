@@ -6,27 +6,47 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"unicode"
 	"unicode/utf8"
 )
 
+const errClosed = "re2: use of closed Regexp"
+
 type Regexp struct {
-	ptr uintptr
+	// handle is allocated separately so that its finalizer can free the
+	// compiled expression even when the Regexp is part of another value, such
+	// as a field that UnmarshalText decodes into.
+	*handle
 
-	posix   bool
-	longest bool
+	opts Options
 
 	expr string
 
 	numMatches int
-	groupNames []string
+
+	groupNames     []string
+	groupNamesOnce sync.Once
+}
+
+// handle is an expression compiled in re2, in the module instance abi in the
+// default WebAssembly mode.
+type handle struct {
+	ptr uintptr
 
 	abi *libre2ABI
 
+	// id identifies the expression to other module instances it is compiled
+	// in, as ptr may be reused for another expression once it is freed.
+	id uint64
+
 	released uint32
 }
 
+// handleIDs is the id of the last compiled handle.
+var handleIDs uint64
+
 // MatchString reports whether the string s
 // contains any match of the regular expression pattern.
 // More complicated queries need to use Compile and the full Regexp interface.
@@ -63,7 +83,11 @@ func (re *Regexp) Copy() *Regexp {
 	// Recompiling is slower than this should be but for a deprecated method it
 	// is probably fine. The alternative would be to have reference counting to
 	// make sure regex is only deleted when the last reference is gone.
-	return MustCompile(re.expr)
+	cp, err := compile(re.expr, re.opts)
+	if err != nil {
+		panic(`regexp: Copy(` + quote(re.expr) + `): ` + err.Error())
+	}
+	return cp
 }
 
 // Compile parses a regular expression and returns, if successful,
@@ -77,7 +101,7 @@ func (re *Regexp) Copy() *Regexp {
 // package implements it without the expense of backtracking.
 // For POSIX leftmost-longest matching, see CompilePOSIX.
 func Compile(expr string) (*Regexp, error) {
-	return compile(expr, false, false, false)
+	return compile(expr, Options{})
 }
 
 // CompilePOSIX is like Compile but restricts the regular expression
@@ -100,68 +124,46 @@ func Compile(expr string) (*Regexp, error) {
 // The POSIX rule is computationally prohibitive and not even well-defined.
 // See https://swtch.com/~rsc/regexp/regexp2.html#posix for details.
 func CompilePOSIX(expr string) (*Regexp, error) {
-	return compile(expr, true, true, false)
+	return compile(expr, Options{POSIXSyntax: true, Longest: true})
 }
 
-func compile(expr string, posix bool, longest bool, caseInsensitive bool) (*Regexp, error) {
-	abi := newABI()
+func compile(expr string, opts Options) (_ *Regexp, err error) {
+	// cre2 exits instead of returning an error for an unknown encoding.
+	if opts.Encoding != EncodingUTF8 && opts.Encoding != EncodingLatin1 {
+		return nil, fmt.Errorf("re2: unknown encoding %d", opts.Encoding)
+	}
+
+	defer recoverMemoryLimit(&err)
+
+	abi := sharedABI()
 	abi.startOperation(len(expr) + 2 + 8)
 	defer abi.endOperation()
 
 	cs := newCString(abi, expr)
 
-	rePtr := newRE(abi, cs, longest, posix, caseInsensitive)
+	rePtr := newRE(abi, cs, opts)
 	errCode, errArg := reError(abi, rePtr)
-	switch errCode {
-	case 0:
-	// No error.
-	case 1:
-		return nil, fmt.Errorf("error parsing regexp: unexpected error: %#q", errArg)
-	case 2:
-		return nil, fmt.Errorf("error parsing regexp: invalid escape sequence: %#q", errArg)
-	case 3:
-		return nil, fmt.Errorf("error parsing regexp: bad character class: %#q", errArg)
-	case 4:
-		return nil, fmt.Errorf("error parsing regexp: invalid character class range: %#q", errArg)
-	case 5:
-		return nil, fmt.Errorf("error parsing regexp: missing closing ]: %#q", errArg)
-	case 6:
-		return nil, fmt.Errorf("error parsing regexp: missing closing ): %#q", errArg)
-	case 7:
-		return nil, fmt.Errorf("error parsing regexp: unexpected ): %#q", errArg)
-	case 8:
-		return nil, fmt.Errorf("error parsing regexp: trailing backslash at end of expression: %#q", errArg)
-	case 9:
-		return nil, fmt.Errorf("error parsing regexp: missing argument to repetition operator: %#q", errArg)
-	case 10:
-		return nil, fmt.Errorf("error parsing regexp: bad repitition argument: %#q", errArg)
-	case 11:
-		return nil, fmt.Errorf("error parsing regexp: invalid nested repetition operator: %#q", errArg)
-	case 12:
-		return nil, fmt.Errorf("error parsing regexp: bad perl operator: %#q", errArg)
-	case 13:
-		return nil, fmt.Errorf("error parsing regexp: invalid UTF-8 in regexp: %#q", errArg)
-	case 14:
-		return nil, fmt.Errorf("error parsing regexp: bad named capture group: %#q", errArg)
-	case 15:
-		// TODO(anuraaga): While the unit test passes, it is likely that the actual limit is currently
-		// different than regexp.
-		return nil, fmt.Errorf("error parsing regexp: expression too large")
+	if errCode != 0 {
+		// re2 allocates the regexp even when it is invalid.
+		deleteRE(abi, rePtr)
+		return nil, newError(expr, errCode, errArg)
 	}
 
 	// Does not include whole expression match, e.g. $0
 	numGroups := numCapturingGroups(abi, rePtr)
+	if opts.NeverCapture {
+		// re2 still counts named groups, but does not capture with them.
+		numGroups = 0
+	}
 
 	re := &Regexp{
-		ptr:        rePtr,
-		posix:      posix,
-		longest:    longest,
+		handle:     &handle{ptr: rePtr, abi: abi, id: atomic.AddUint64(&handleIDs, 1)},
+		opts:       opts,
 		expr:       expr,
 		numMatches: numGroups + 1,
-		abi:        abi,
 	}
 
-	runtime.SetFinalizer(re, (*Regexp).release)
+	runtime.SetFinalizer(re.handle, (*handle).release)
 
 	return re, nil
 }
@@ -279,14 +281,14 @@ func (re *Regexp) expand(dst []byte, template string, bsrc []byte, src string, m
 // Find returns a slice holding the text of the leftmost match in b of the regular expression.
 // A return value of nil indicates no match.
 func (re *Regexp) Find(b []byte) []byte {
-	re.abi.startOperation(len(b) + 8)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(b) + 8)
+	defer inst.abi.endOperation()
 
-	cs := newCStringFromBytes(re.abi, b)
+	cs := newCStringFromBytes(inst.abi, b)
 
 	var dstCap [2]int
 
-	dst := re.find(cs, dstCap[:0])
+	dst := inst.find(cs, Unanchored, dstCap[:0])
 	return matchedBytes(b, dst)
 }
 
@@ -295,11 +297,11 @@ func (re *Regexp) Find(b []byte) []byte {
 // b[loc[0]:loc[1]].
 // A return value of nil indicates no match.
 func (re *Regexp) FindIndex(b []byte) (loc []int) {
-	re.abi.startOperation(len(b) + 8)
-	defer re.abi.endOperation()
-	cs := newCStringFromBytes(re.abi, b)
+	inst := re.startOperation(len(b) + 8)
+	defer inst.abi.endOperation()
+	cs := newCStringFromBytes(inst.abi, b)
 
-	return re.find(cs, nil)
+	return inst.find(cs, Unanchored, nil)
 }
 
 // FindString returns a string holding the text of the leftmost match in s of the regular
@@ -308,13 +310,13 @@ func (re *Regexp) FindIndex(b []byte) (loc []int) {
 // an empty string. Use FindStringIndex or FindStringSubmatch if it is
 // necessary to distinguish these cases.
 func (re *Regexp) FindString(s string) string {
-	re.abi.startOperation(len(s) + 8)
-	defer re.abi.endOperation()
-	cs := newCString(re.abi, s)
+	inst := re.startOperation(len(s) + 8)
+	defer inst.abi.endOperation()
+	cs := newCString(inst.abi, s)
 
 	var dstCap [2]int
 
-	dst := re.find(cs, dstCap[:0])
+	dst := inst.find(cs, Unanchored, dstCap[:0])
 	return matchedString(s, dst)
 }
 
@@ -323,17 +325,17 @@ func (re *Regexp) FindString(s string) string {
 // itself is at s[loc[0]:loc[1]].
 // A return value of nil indicates no match.
 func (re *Regexp) FindStringIndex(s string) (loc []int) {
-	re.abi.startOperation(len(s) + 8)
-	defer re.abi.endOperation()
-	cs := newCString(re.abi, s)
+	inst := re.startOperation(len(s) + 8)
+	defer inst.abi.endOperation()
+	cs := newCString(inst.abi, s)
 
-	return re.find(cs, nil)
+	return inst.find(cs, Unanchored, nil)
 }
 
-func (re *Regexp) find(cs cString, dstCap []int) []int {
+func (re *Regexp) find(cs cString, anchor Anchor, dstCap []int) []int {
 	matchArr := newCStringArray(re.abi, 1)
 
-	res := match(re, cs, matchArr.ptr, 1)
+	res := match(re, cs, anchor.cre2(), matchArr.ptr, 1)
 	if !res {
 		return nil
 	}
@@ -346,14 +348,14 @@ func (re *Regexp) find(cs cString, dstCap []int) []int {
 // package comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindAll(b []byte, n int) [][]byte {
-	re.abi.startOperation(len(b) + 16)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(b) + findAllMemorySize(1))
+	defer inst.abi.endOperation()
 
-	cs := newCStringFromBytes(re.abi, b)
+	cs := newCStringFromBytes(inst.abi, b)
 
 	var matches [][]byte
 
-	re.findAll(cs, n, func(match []int) {
+	inst.findAll(cs, n, func(match []int) {
 		matches = append(matches, matchedBytes(b, match))
 	})
 
@@ -365,14 +367,14 @@ func (re *Regexp) FindAll(b []byte, n int) [][]byte {
 // in the package comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
-	re.abi.startOperation(len(b) + 16)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(b) + findAllMemorySize(1))
+	defer inst.abi.endOperation()
 
-	cs := newCStringFromBytes(re.abi, b)
+	cs := newCStringFromBytes(inst.abi, b)
 
 	var matches [][]int
 
-	re.findAll(cs, n, func(match []int) {
+	inst.findAll(cs, n, func(match []int) {
 		matches = append(matches, append([]int(nil), match...))
 	})
 
@@ -384,14 +386,14 @@ func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
 // in the package comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindAllString(s string, n int) []string {
-	re.abi.startOperation(len(s) + 16)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(s) + findAllMemorySize(1))
+	defer inst.abi.endOperation()
 
-	cs := newCString(re.abi, s)
+	cs := newCString(inst.abi, s)
 
 	var matches []string
 
-	re.findAll(cs, n, func(match []int) {
+	inst.findAll(cs, n, func(match []int) {
 		matches = append(matches, matchedString(s, match))
 	})
 
@@ -403,59 +405,75 @@ func (re *Regexp) FindAllString(s string, n int) []string {
 // description in the package comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
-	re.abi.startOperation(len(s) + 16)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(s) + findAllMemorySize(1))
+	defer inst.abi.endOperation()
 
-	cs := newCString(re.abi, s)
+	cs := newCString(inst.abi, s)
 
 	var matches [][]int
 
-	re.findAll(cs, n, func(match []int) {
+	inst.findAll(cs, n, func(match []int) {
 		matches = append(matches, append([]int(nil), match...))
 	})
 
 	return matches
 }
 
+// findAllBatch is the most matches re2 finds in a single call when finding
+// all matches, which bounds the memory to read them back.
+const findAllBatch = 64
+
+// findAllMemorySize returns the memory needed beyond the text to find all
+// matches with numGroups index pairs each.
+func findAllMemorySize(numGroups int) int {
+	return 8*numGroups*findAllBatch + 8
+}
+
 func (re *Regexp) findAll(cs cString, n int, deliver func(match []int)) {
-	var dstCap [2]int
+	re.findMatches(cs, n, 1, deliver)
+}
 
-	if n < 0 {
+func (re *Regexp) findAllSubmatch(cs cString, n int, deliver func(match []int)) {
+	re.findMatches(cs, n, re.numMatches, deliver)
+}
+
+// findMatches calls deliver with the index pairs of each successive match in
+// cs and of its subexpressions, numGroups pairs in all, stopping after n
+// matches if n is not negative. re2 iterates over the matches itself, with the
+// same handling of empty matches as regexp, finding up to findAllBatch of them
+// in each call. The slice passed to deliver is reused.
+func (re *Regexp) findMatches(cs cString, n int, numGroups int, deliver func(match []int)) {
+	if n == 0 {
+		return
+	}
+	if n < 0 || n > cs.length+1 {
 		n = cs.length + 1
 	}
 
-	matchArr := newCStringArray(re.abi, 1)
+	matchArr := newCStringArray(re.abi, numGroups*findAllBatch)
+	state := newFindAllState(re.abi, 0, -1)
+	utf8 := re.opts.Encoding != EncodingLatin1
 
-	count := 0
-	prevMatchEnd := -1
-	pos := 0
-	for pos < cs.length+1 {
-		if !matchFrom(re, cs, pos, matchArr.ptr, 1) {
-			break
+	match := make([]int, 0, 2*numGroups)
+	for n > 0 {
+		batch := n
+		if batch > findAllBatch {
+			batch = findAllBatch
 		}
 
-		matches := readMatch(re.abi, cs, matchArr.ptr, dstCap[:0])
-		accept := true
-		if matches[0] == matches[1] {
-			// We've found an empty match.
-			if matches[0] == prevMatchEnd {
-				// We don't allow an empty match right
-				// after a previous match, so ignore it.
-				accept = false
+		found := findAllFrom(re, cs, state, utf8, matchArr.ptr, numGroups, batch)
+		readMatches(re.abi, cs, matchArr.ptr, found*numGroups, func(group []int) {
+			match = append(match, group...)
+			if len(match) == cap(match) {
+				deliver(match)
+				match = match[:0]
 			}
-			pos++
-		} else {
-			pos = matches[1]
-		}
-		if accept {
-			deliver(matches)
-			count++
-		}
-		prevMatchEnd = matches[1]
+		})
 
-		if count == n {
-			break
+		if found < batch {
+			return
 		}
+		n -= found
 	}
 }
 
@@ -464,17 +482,17 @@ func (re *Regexp) findAll(cs cString, n int, deliver func(match []int)) {
 // description in the package comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindAllSubmatch(b []byte, n int) [][][]byte {
-	re.abi.startOperation(len(b) + 8*re.numMatches + 8)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(b) + findAllMemorySize(re.numMatches))
+	defer inst.abi.endOperation()
 
-	cs := newCStringFromBytes(re.abi, b)
+	cs := newCStringFromBytes(inst.abi, b)
 
 	var matches [][][]byte
 
-	re.findAllSubmatch(cs, n, func(match [][]int) {
-		matched := make([][]byte, len(match))
-		for i, m := range match {
-			matched[i] = matchedBytes(b, m)
+	inst.findAllSubmatch(cs, n, func(match []int) {
+		matched := make([][]byte, len(match)/2)
+		for i := range matched {
+			matched[i] = matchedBytes(b, match[2*i:])
 		}
 		matches = append(matches, matched)
 	})
@@ -487,19 +505,15 @@ func (re *Regexp) FindAllSubmatch(b []byte, n int) [][][]byte {
 // 'All' description in the package comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindAllSubmatchIndex(b []byte, n int) [][]int {
-	re.abi.startOperation(len(b) + 8*re.numMatches + 8)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(b) + findAllMemorySize(re.numMatches))
+	defer inst.abi.endOperation()
 
-	cs := newCStringFromBytes(re.abi, b)
+	cs := newCStringFromBytes(inst.abi, b)
 
 	var matches [][]int
 
-	re.findAllSubmatch(cs, n, func(match [][]int) {
-		var flat []int
-		for _, m := range match {
-			flat = append(flat, m...)
-		}
-		matches = append(matches, flat)
+	inst.findAllSubmatch(cs, n, func(match []int) {
+		matches = append(matches, append([]int(nil), match...))
 	})
 
 	return matches
@@ -510,17 +524,17 @@ func (re *Regexp) FindAllSubmatchIndex(b []byte, n int) [][]int {
 // the 'All' description in the package comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
-	re.abi.startOperation(len(s) + 8*re.numMatches + 8)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(s) + findAllMemorySize(re.numMatches))
+	defer inst.abi.endOperation()
 
-	cs := newCString(re.abi, s)
+	cs := newCString(inst.abi, s)
 
 	var matches [][]string
 
-	re.findAllSubmatch(cs, n, func(match [][]int) {
-		matched := make([]string, len(match))
-		for i, m := range match {
-			matched[i] = matchedString(s, m)
+	inst.findAllSubmatch(cs, n, func(match []int) {
+		matched := make([]string, len(match)/2)
+		for i := range matched {
+			matched[i] = matchedString(s, match[2*i:])
 		}
 		matches = append(matches, matched)
 	})
@@ -534,83 +548,34 @@ func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
 // comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
-	re.abi.startOperation(len(s) + 8*re.numMatches + 8)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(s) + findAllMemorySize(re.numMatches))
+	defer inst.abi.endOperation()
 
-	cs := newCString(re.abi, s)
+	cs := newCString(inst.abi, s)
 
 	var matches [][]int
 
-	re.findAllSubmatch(cs, n, func(match [][]int) {
-		var flat []int
-		for _, m := range match {
-			flat = append(flat, m...)
-		}
-		matches = append(matches, flat)
+	inst.findAllSubmatch(cs, n, func(match []int) {
+		matches = append(matches, append([]int(nil), match...))
 	})
 
 	return matches
 }
 
-func (re *Regexp) findAllSubmatch(cs cString, n int, deliver func(match [][]int)) {
-	if n < 0 {
-		n = cs.length + 1
-	}
-
-	numGroups := re.numMatches
-	matchArr := newCStringArray(re.abi, numGroups)
-
-	count := 0
-	prevMatchEnd := -1
-	pos := 0
-	for pos < cs.length+1 {
-		if !matchFrom(re, cs, pos, matchArr.ptr, uint32(numGroups)) {
-			break
-		}
-
-		var matches [][]int
-		accept := true
-		readMatches(re.abi, cs, matchArr.ptr, numGroups, func(match []int) {
-			if len(matches) == 0 {
-				// First match, check if it's an empty match following a match, which we ignore.
-				// TODO: Don't iterate further when ignoring.
-				if match[0] == match[1] {
-					if match[0] == prevMatchEnd {
-						accept = false
-					}
-					pos++
-				} else {
-					pos = match[1]
-				}
-				prevMatchEnd = match[1]
-			}
-			matches = append(matches, append([]int(nil), match...))
-		})
-		if accept {
-			deliver(matches)
-		}
-		count++
-
-		if count == n {
-			break
-		}
-	}
-}
-
 // FindSubmatch returns a slice of slices holding the text of the leftmost
 // match of the regular expression in b and the matches, if any, of its
 // subexpressions, as defined by the 'Submatch' descriptions in the package
 // comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindSubmatch(b []byte) [][]byte {
-	re.abi.startOperation(len(b) + 8*re.numMatches)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(b) + 8*re.numMatches)
+	defer inst.abi.endOperation()
 
-	cs := newCStringFromBytes(re.abi, b)
+	cs := newCStringFromBytes(inst.abi, b)
 
 	var matches [][]byte
 
-	re.findSubmatch(cs, func(match []int) {
+	inst.findSubmatch(cs, Unanchored, func(match []int) {
 		matches = append(matches, matchedBytes(b, match))
 	})
 
@@ -623,14 +588,14 @@ func (re *Regexp) FindSubmatch(b []byte) [][]byte {
 // in the package comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindSubmatchIndex(b []byte) []int {
-	re.abi.startOperation(len(b) + 8*re.numMatches)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(b) + 8*re.numMatches)
+	defer inst.abi.endOperation()
 
-	cs := newCStringFromBytes(re.abi, b)
+	cs := newCStringFromBytes(inst.abi, b)
 
 	var matches []int
 
-	re.findSubmatch(cs, func(match []int) {
+	inst.findSubmatch(cs, Unanchored, func(match []int) {
 		matches = append(matches, match...)
 	})
 
@@ -638,14 +603,14 @@ func (re *Regexp) FindSubmatchIndex(b []byte) []int {
 }
 
 func (re *Regexp) FindStringSubmatch(s string) []string {
-	re.abi.startOperation(len(s) + 8*re.numMatches)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(s) + 8*re.numMatches)
+	defer inst.abi.endOperation()
 
-	cs := newCString(re.abi, s)
+	cs := newCString(inst.abi, s)
 
 	var matches []string
 
-	re.findSubmatch(cs, func(match []int) {
+	inst.findSubmatch(cs, Unanchored, func(match []int) {
 		matches = append(matches, matchedString(s, match))
 	})
 
@@ -658,25 +623,25 @@ func (re *Regexp) FindStringSubmatch(s string) []string {
 // 'Index' descriptions in the package comment.
 // A return value of nil indicates no match.
 func (re *Regexp) FindStringSubmatchIndex(s string) []int {
-	re.abi.startOperation(len(s) + 8*re.numMatches)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(s) + 8*re.numMatches)
+	defer inst.abi.endOperation()
 
-	cs := newCString(re.abi, s)
+	cs := newCString(inst.abi, s)
 
 	var matches []int
 
-	re.findSubmatch(cs, func(match []int) {
+	inst.findSubmatch(cs, Unanchored, func(match []int) {
 		matches = append(matches, match...)
 	})
 
 	return matches
 }
 
-func (re *Regexp) findSubmatch(cs cString, deliver func(match []int)) {
+func (re *Regexp) findSubmatch(cs cString, anchor Anchor, deliver func(match []int)) {
 	numGroups := re.numMatches
 	matchArr := newCStringArray(re.abi, numGroups)
 
-	if !match(re, cs, matchArr.ptr, uint32(numGroups)) {
+	if !match(re, cs, anchor.cre2(), matchArr.ptr, uint32(numGroups)) {
 		return
 	}
 
@@ -690,18 +655,27 @@ func (re *Regexp) findSubmatch(cs cString, deliver func(match []int)) {
 // This method modifies the Regexp and may not be called concurrently
 // with any other methods.
 func (re *Regexp) Longest() {
-	re.abi.startOperation(len(re.expr) + 2)
-	defer re.abi.endOperation()
-
-	if re.longest {
+	if atomic.LoadUint32(&re.released) == 1 {
+		panic(errClosed)
+	}
+	if re.opts.Longest {
 		return
 	}
 
+	// Instances in other modules would keep matching leftmost-first, so they
+	// are dropped and not found again.
+	releaseInstances(re.handle)
+	re.id = atomic.AddUint64(&handleIDs, 1)
+
+	re.abi.startOperation(len(re.expr) + 2)
+	defer re.abi.endOperation()
+
 	// longest is not a mutable option in re2 so we must release and recompile.
 	deleteRE(re.abi, re.ptr)
 
+	re.opts.Longest = true
 	cs := newCString(re.abi, re.expr)
-	re.ptr = newRE(re.abi, cs, true, re.posix, false)
+	re.ptr = newRE(re.abi, cs, re.opts)
 }
 
 // NumSubexp returns the number of parenthesized subexpressions in this Regexp.
@@ -770,9 +744,14 @@ func (re *Regexp) Split(s string, n int) []string {
 // Since the Regexp as a whole cannot be named, names[0] is always
 // the empty string. The slice should not be modified.
 func (re *Regexp) SubexpNames() []string {
-	if re.groupNames == nil {
-		re.groupNames = subexpNames(re.abi, re.ptr, re.numMatches)
+	if atomic.LoadUint32(&re.released) == 1 {
+		panic(errClosed)
 	}
+	re.groupNamesOnce.Do(func() {
+		inst := re.startOperation(0)
+		defer inst.abi.endOperation()
+		re.groupNames = subexpNames(inst.abi, inst.ptr, re.numMatches)
+	})
 	return re.groupNames
 }
 
@@ -794,14 +773,32 @@ func (re *Regexp) SubexpIndex(name string) int {
 	return -1
 }
 
+// PossibleMatchRange returns strings min and max such that any string
+// beginning with a match of re is lexicographically between them, inclusive.
+// Only the first maxLen bytes of a match are considered, so the range may be
+// wider than necessary. This is useful for turning an expression into a range
+// scan over sorted keys before running the expression on the candidates.
+//
+// ok is false when no useful range can be computed, for example when a match
+// may begin with any byte, as in `\C*`, or when maxLen is zero.
+func (re *Regexp) PossibleMatchRange(maxLen int) (min, max string, ok bool) {
+	inst := re.startOperation(16)
+	defer inst.abi.endOperation()
+
+	rangeArr := newCStringArray(inst.abi, 2)
+	min, max, ok = possibleMatchRange(inst, rangeArr.ptr, maxLen)
+	runtime.KeepAlive(rangeArr)
+	return min, max, ok
+}
+
 // Match reports whether the byte slice b
 // contains any match of the regular expression re.
 func (re *Regexp) Match(b []byte) bool {
-	re.abi.startOperation(len(b))
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(b))
+	defer inst.abi.endOperation()
 
-	cs := newCStringFromBytes(re.abi, b)
-	res := match(re, cs, 0, 0)
+	cs := newCStringFromBytes(inst.abi, b)
+	res := match(inst, cs, Unanchored.cre2(), 0, 0)
 	runtime.KeepAlive(b)
 	return res
 }
@@ -809,20 +806,40 @@ func (re *Regexp) Match(b []byte) bool {
 // MatchString reports whether the string s
 // contains any match of the regular expression re.
 func (re *Regexp) MatchString(s string) bool {
-	re.abi.startOperation(len(s))
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(s))
+	defer inst.abi.endOperation()
 
-	cs := newCString(re.abi, s)
-	res := match(re, cs, 0, 0)
+	cs := newCString(inst.abi, s)
+	res := match(inst, cs, Unanchored.cre2(), 0, 0)
 	runtime.KeepAlive(s)
 	return res
 }
 
-func (re *Regexp) release() {
-	if !atomic.CompareAndSwapUint32(&re.released, 0, 1) {
+// Close releases the memory used by re2 for the Regexp immediately, rather
+// than when the Regexp is garbage collected. This can be useful when using
+// cgo or TinyGo, where the garbage collector is not aware of this memory.
+// Calling methods that need re2 after Close panics. Close may be called
+// multiple times, and may not be called concurrently with any other methods.
+func (re *Regexp) Close() {
+	runtime.SetFinalizer(re.handle, nil)
+	re.release()
+}
+
+// startOperation prepares an operation on re, which must not be closed. It
+// returns re as compiled in the module instance that runs the operation, which
+// must be used until the operation is ended with its abi's endOperation.
+func (re *Regexp) startOperation(memorySize int) *Regexp {
+	if atomic.LoadUint32(&re.released) == 1 {
+		panic(errClosed)
+	}
+	return startOperation(re, memorySize)
+}
+
+func (h *handle) release() {
+	if !atomic.CompareAndSwapUint32(&h.released, 0, 1) {
 		return
 	}
-	release(re)
+	release(h)
 }
 
 // ReplaceAll returns a copy of src, replacing matches of the Regexp
@@ -833,13 +850,13 @@ func (re *Regexp) ReplaceAll(src, repl []byte) []byte {
 	// so follow suit for now.
 	replRE2 := convertReplacement(string(repl), re.SubexpNames())
 
-	re.abi.startOperation(len(src) + len(replRE2) + 16)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(src) + len(replRE2) + 16)
+	defer inst.abi.endOperation()
 
-	srcCS := newCStringFromBytes(re.abi, src)
+	srcCS := newCStringFromBytes(inst.abi, src)
 
-	res, matched := re.replaceAll(srcCS, replRE2)
-	if !matched {
+	res, count := inst.replaceAll(srcCS, replRE2)
+	if count == 0 {
 		return src
 	}
 	return res
@@ -851,13 +868,13 @@ func (re *Regexp) ReplaceAll(src, repl []byte) []byte {
 func (re *Regexp) ReplaceAllLiteral(src, repl []byte) []byte {
 	replRE2 := []byte(escapeReplacement(string(repl)))
 
-	re.abi.startOperation(len(src) + len(replRE2) + 16)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(src) + len(replRE2) + 16)
+	defer inst.abi.endOperation()
 
-	srcCS := newCStringFromBytes(re.abi, src)
+	srcCS := newCStringFromBytes(inst.abi, src)
 
-	res, matched := re.replaceAll(srcCS, replRE2)
-	if !matched {
+	res, count := inst.replaceAll(srcCS, replRE2)
+	if count == 0 {
 		return src
 	}
 
@@ -870,13 +887,13 @@ func (re *Regexp) ReplaceAllLiteral(src, repl []byte) []byte {
 func (re *Regexp) ReplaceAllLiteralString(src, repl string) string {
 	replRE2 := []byte(escapeReplacement(repl))
 
-	re.abi.startOperation(len(src) + len(replRE2) + 16)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(src) + len(replRE2) + 16)
+	defer inst.abi.endOperation()
 
-	srcCS := newCString(re.abi, src)
+	srcCS := newCString(inst.abi, src)
 
-	res, matched := re.replaceAll(srcCS, replRE2)
-	if !matched {
+	res, count := inst.replaceAll(srcCS, replRE2)
+	if count == 0 {
 		return src
 	}
 
@@ -889,30 +906,66 @@ func (re *Regexp) ReplaceAllLiteralString(src, repl string) string {
 func (re *Regexp) ReplaceAllString(src, repl string) string {
 	replRE2 := convertReplacement(repl, re.SubexpNames())
 
-	re.abi.startOperation(len(src) + len(replRE2) + 16)
-	defer re.abi.endOperation()
+	inst := re.startOperation(len(src) + len(replRE2) + 16)
+	defer inst.abi.endOperation()
 
-	srcCS := newCString(re.abi, src)
+	srcCS := newCString(inst.abi, src)
 
-	res, matched := re.replaceAll(srcCS, replRE2)
-	if !matched {
+	res, count := inst.replaceAll(srcCS, replRE2)
+	if count == 0 {
 		return src
 	}
 
 	return string(res)
 }
 
-func (re *Regexp) replaceAll(srcCS cString, repl []byte) ([]byte, bool) {
+// ReplaceAllFunc returns a copy of src in which all matches of the
+// Regexp have been replaced by the return value of function repl applied
+// to the matched byte slice. The replacement returned by repl is substituted
+// directly, without using Expand.
+func (re *Regexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
+	// re2 cannot call back into Go, so matches are found up front. This also
+	// allows repl to use re itself.
+	matches := re.FindAllIndex(src, -1)
+
+	var dst []byte
+	lastMatchEnd := 0
+	for _, match := range matches {
+		dst = append(dst, src[lastMatchEnd:match[0]]...)
+		dst = append(dst, repl(src[match[0]:match[1]])...)
+		lastMatchEnd = match[1]
+	}
+	return append(dst, src[lastMatchEnd:]...)
+}
+
+// ReplaceAllStringFunc returns a copy of src in which all matches of the
+// Regexp have been replaced by the return value of function repl applied
+// to the matched substring. The replacement returned by repl is substituted
+// directly, without using Expand.
+func (re *Regexp) ReplaceAllStringFunc(src string, repl func(string) string) string {
+	// re2 cannot call back into Go, so matches are found up front. This also
+	// allows repl to use re itself.
+	matches := re.FindAllStringIndex(src, -1)
+
+	var dst []byte
+	lastMatchEnd := 0
+	for _, match := range matches {
+		dst = append(dst, src[lastMatchEnd:match[0]]...)
+		dst = append(dst, repl(src[match[0]:match[1]])...)
+		lastMatchEnd = match[1]
+	}
+	return string(append(dst, src[lastMatchEnd:]...))
+}
+
+// replaceAll replaces all matches in srcCS with repl in re2's rewrite syntax,
+// returning the result and the number of replacements.
+func (re *Regexp) replaceAll(srcCS cString, repl []byte) ([]byte, int) {
 	replCS := newCStringFromBytes(re.abi, repl)
 
 	replCSPtr := newCStringPtr(re.abi, replCS)
 	srcCSPtr := newCStringPtr(re.abi, srcCS)
 
-	res, matched := globalReplace(re, srcCSPtr.ptr, replCSPtr.ptr)
-	if !matched {
-		return nil, false
-	}
-	return res, true
+	return globalReplace(re, srcCSPtr.ptr, replCSPtr.ptr)
 }
 
 // String returns the source text used to compile the regular expression.
@@ -931,7 +984,10 @@ func subexpNames(abi *libre2ABI, rePtr uintptr, numMatches int) []string {
 		if !ok {
 			break
 		}
-		res[index] = name
+		// Named groups are not counted with NeverCapture.
+		if index < numMatches {
+			res[index] = name
+		}
 	}
 
 	return res
@@ -3,6 +3,8 @@
 package re2
 
 import (
+	"bytes"
+	"context"
 	"reflect"
 	"unsafe"
 
@@ -11,29 +13,85 @@ import (
 
 type libre2ABI struct{}
 
-func newABI() *libre2ABI {
+func sharedABI() *libre2ABI {
 	return &libre2ABI{}
 }
 
 func (abi *libre2ABI) startOperation(memorySize int) {
 }
 
+// startOperation returns re, which can be used concurrently without any
+// preparation.
+func startOperation(re *Regexp, _ int) *Regexp {
+	return re
+}
+
+func releaseInstances(_ *handle) {
+}
+
+func configureRuntime(_ RuntimeConfig) error {
+	return nil
+}
+
+func memoryLimitChanged() {
+}
+
+func warmup() {
+}
+
+// runContext runs op with re. Calls into re2 cannot be interrupted, so ctx is
+// only checked before starting.
+func runContext(re *Regexp, ctx context.Context, _ int, op func(inst *Regexp)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	op(re)
+	return nil
+}
+
 func (abi *libre2ABI) endOperation() {
 }
 
-func newRE(abi *libre2ABI, pattern cString, longest bool, posix bool, caseInsensitive bool) uintptr {
+func newRE(abi *libre2ABI, pattern cString, opts Options) uintptr {
 	opt := cre2.NewOpt()
 	defer cre2.DeleteOpt(opt)
 	cre2.OptSetLogErrors(opt, false)
-	if longest {
+	if opts.Longest {
 		cre2.OptSetLongestMatch(opt, true)
 	}
-	if posix {
+	if opts.POSIXSyntax {
 		cre2.OptSetPosixSyntax(opt, true)
 	}
-	if caseInsensitive {
+	if opts.CaseInsensitive {
 		cre2.OptSetCaseSensitive(opt, false)
 	}
+	if opts.Literal {
+		cre2.OptSetLiteral(opt, true)
+	}
+	if opts.NeverNL {
+		cre2.OptSetNeverNL(opt, true)
+	}
+	if opts.DotNL {
+		cre2.OptSetDotNL(opt, true)
+	}
+	if opts.NeverCapture {
+		cre2.OptSetNeverCapture(opt, true)
+	}
+	if opts.PerlClasses {
+		cre2.OptSetPerlClasses(opt, true)
+	}
+	if opts.WordBoundary {
+		cre2.OptSetWordBoundary(opt, true)
+	}
+	if opts.OneLine {
+		cre2.OptSetOneLine(opt, true)
+	}
+	if opts.MaxMem > 0 {
+		cre2.OptSetMaxMem(opt, opts.MaxMem)
+	}
+	if opts.Encoding != EncodingUTF8 {
+		cre2.OptSetEncoding(opt, opts.Encoding.cre2())
+	}
 	return uintptr(cre2.New(unsafe.Pointer(uintptr(pattern.ptr)), int(pattern.length), opt))
 }
 
@@ -53,22 +111,125 @@ func numCapturingGroups(abi *libre2ABI, rePtr uintptr) int {
 	return cre2.NumCapturingGroups(unsafe.Pointer(rePtr))
 }
 
+func programSize(re *Regexp) int {
+	return cre2.ProgramSize(unsafe.Pointer(re.ptr))
+}
+
+func reverseProgramSize(re *Regexp) int {
+	return cre2.ReverseProgramSize(unsafe.Pointer(re.ptr))
+}
+
+func programFanout(re *Regexp) []int {
+	var buf [fanoutBuckets]int32
+	n := cre2.ProgramFanout(unsafe.Pointer(re.ptr), unsafe.Pointer(&buf[0]), fanoutBuckets)
+
+	histogram := make([]int, n)
+	for i := range histogram {
+		histogram[i] = int(buf[i])
+	}
+	return histogram
+}
+
 func deleteRE(_ *libre2ABI, rePtr uintptr) {
 	cre2.Delete(unsafe.Pointer(rePtr))
 }
 
-func release(re *Regexp) {
-	deleteRE(re.abi, re.ptr)
+func release(h *handle) {
+	deleteRE(h.abi, h.ptr)
 }
 
-func match(re *Regexp, s cString, matchesPtr uintptr, nMatches uint32) bool {
+func match(re *Regexp, s cString, anchor int, matchesPtr uintptr, nMatches uint32) bool {
 	return cre2.Match(unsafe.Pointer(re.ptr), unsafe.Pointer(s.ptr),
-		int(s.length), 0, int(s.length), 0, unsafe.Pointer(matchesPtr), int(nMatches))
+		int(s.length), 0, int(s.length), anchor, unsafe.Pointer(matchesPtr), int(nMatches))
 }
 
-func matchFrom(re *Regexp, s cString, startPos int, matchesPtr uintptr, nMatches uint32) bool {
+func matchFrom(re *Regexp, s cString, startPos int, endPos int, matchesPtr uintptr, nMatches uint32) bool {
 	return cre2.Match(unsafe.Pointer(re.ptr), unsafe.Pointer(s.ptr),
-		int(s.length), startPos, int(s.length), 0, unsafe.Pointer(matchesPtr), int(nMatches))
+		int(s.length), startPos, endPos, 0, unsafe.Pointer(matchesPtr), int(nMatches))
+}
+
+// findAllState is where re2 keeps the position of a search for all matches
+// between calls.
+type findAllState struct {
+	state *[2]int32
+}
+
+func newFindAllState(_ *libre2ABI, pos int, prevMatchEnd int) findAllState {
+	return findAllState{state: &[2]int32{int32(pos), int32(prevMatchEnd)}}
+}
+
+func (s findAllState) read(_ *libre2ABI) (pos int, prevMatchEnd int) {
+	return int(s.state[0]), int(s.state[1])
+}
+
+func findAllFrom(re *Regexp, cs cString, state findAllState, utf8 bool, matchesPtr uintptr, nMatches int, maxMatches int) int {
+	return cre2.FindAll(unsafe.Pointer(re.ptr), unsafe.Pointer(cs.ptr), cs.length, unsafe.Pointer(state.state), utf8,
+		unsafe.Pointer(matchesPtr), nMatches, maxMatches)
+}
+
+func matchBatch(re *Regexp, texts []cString, anchor int, matchesPtr uintptr, nMatches uint32, deliver func(i int, matchPtr uintptr)) {
+	matched := make([]int32, len(texts))
+	n := cre2.MatchBatch(unsafe.Pointer(re.ptr), unsafe.Pointer(&texts[0]), len(texts), anchor,
+		unsafe.Pointer(&matched[0]), unsafe.Pointer(matchesPtr), int(nMatches))
+	if n == 0 {
+		return
+	}
+
+	for i, m := range matched {
+		if m != 0 {
+			deliver(i, matchesPtr+unsafe.Sizeof(cString{})*uintptr(int(nMatches)*i))
+		}
+	}
+}
+
+func newSet(_ *libre2ABI, anchor int) uintptr {
+	opt := cre2.NewOpt()
+	defer cre2.DeleteOpt(opt)
+	cre2.OptSetLogErrors(opt, false)
+	return uintptr(cre2.NewSet(opt, anchor))
+}
+
+func deleteSet(_ *libre2ABI, setPtr uintptr) {
+	cre2.DeleteSet(unsafe.Pointer(setPtr))
+}
+
+func releaseSet(set *Set) {
+	deleteSet(set.abi, set.ptr)
+}
+
+func setAdd(set *Set, pattern cString, errLen int) (int, string) {
+	errBuf := make([]byte, errLen)
+	idx := cre2.SetAdd(unsafe.Pointer(set.ptr), unsafe.Pointer(pattern.ptr), pattern.length, unsafe.Pointer(&errBuf[0]), errLen)
+	if idx >= 0 {
+		return idx, ""
+	}
+
+	// C-string, read content until NULL.
+	if n := bytes.IndexByte(errBuf, 0); n >= 0 {
+		errBuf = errBuf[:n]
+	}
+	return idx, string(errBuf)
+}
+
+func setCompile(set *Set) bool {
+	return cre2.SetCompile(unsafe.Pointer(set.ptr))
+}
+
+func setMatch(set *Set, cs cString, n int) []int {
+	matchesBuf := make([]int32, n)
+	count := cre2.SetMatch(unsafe.Pointer(set.ptr), unsafe.Pointer(cs.ptr), cs.length, unsafe.Pointer(&matchesBuf[0]), n)
+	if count == 0 {
+		return nil
+	}
+	if count > n {
+		count = n
+	}
+
+	matches := make([]int, count)
+	for i := range matches {
+		matches[i] = int(matchesBuf[i])
+	}
+	return matches
 }
 
 type cString struct {
@@ -99,6 +260,24 @@ func newCStringFromBytes(_ *libre2ABI, s []byte) cString {
 	}
 }
 
+// residentString is a string that a sequence of operations runs on. re2
+// reads it in place, so it is never copied.
+type residentString struct {
+	s  string
+	cs cString
+}
+
+func newResidentString(_ *Regexp, s string) *residentString {
+	return &residentString{s: s, cs: newCString(nil, s)}
+}
+
+func (s *residentString) startOperation(re *Regexp, _ int) (*Regexp, bool) {
+	return re, true
+}
+
+func (s *residentString) release() {
+}
+
 func newCStringPtr(_ *libre2ABI, cs cString) pointer {
 	return pointer{ptr: uintptr(unsafe.Pointer(&cs))}
 }
@@ -138,18 +317,45 @@ func namedGroupsIterDelete(_ *libre2ABI, iterPtr uintptr) {
 	cre2.NamedGroupsIterDelete(unsafe.Pointer(uintptr(iterPtr)))
 }
 
-func globalReplace(re *Regexp, textAndTargetPtr uintptr, rewritePtr uintptr) ([]byte, bool) {
-	if !cre2.GlobalReplace(unsafe.Pointer(re.ptr), unsafe.Pointer(textAndTargetPtr), unsafe.Pointer(rewritePtr)) {
-		// No replacements
-		return nil, false
+func globalReplace(re *Regexp, textAndTargetPtr uintptr, rewritePtr uintptr) ([]byte, int) {
+	count := cre2.GlobalReplace(unsafe.Pointer(re.ptr), unsafe.Pointer(textAndTargetPtr), unsafe.Pointer(rewritePtr))
+	if count == -1 {
+		panic("out of memory")
 	}
 
 	textAndTarget := (*cString)(unsafe.Pointer(textAndTargetPtr))
 	// This was malloc'd by cre2, so free it
 	defer cre2.Free(unsafe.Pointer(textAndTarget.ptr))
 
+	if count == 0 {
+		// No replacements
+		return nil, 0
+	}
+
+	// content of buf will be free'd, so copy it
+	return cre2.CopyCBytes(unsafe.Pointer(textAndTarget.ptr), textAndTarget.length), count
+}
+
+func possibleMatchRange(re *Regexp, rangePtr uintptr, maxLen int) (string, string, bool) {
+	minCS := (*cString)(unsafe.Pointer(rangePtr))
+	maxCS := (*cString)(unsafe.Pointer(rangePtr + unsafe.Sizeof(cString{})))
+
+	res := cre2.PossibleMatchRange(unsafe.Pointer(re.ptr), unsafe.Pointer(minCS), unsafe.Pointer(maxCS), maxLen)
+	if res == -1 {
+		panic("out of memory")
+	}
+	if res == 0 {
+		return "", "", false
+	}
+
+	// These were malloc'd by cre2, so free them
+	defer cre2.Free(unsafe.Pointer(minCS.ptr))
+	defer cre2.Free(unsafe.Pointer(maxCS.ptr))
+
 	// content of buf will be free'd, so copy it
-	return cre2.CopyCBytes(unsafe.Pointer(textAndTarget.ptr), textAndTarget.length), true
+	minStr := string(cre2.CopyCBytes(unsafe.Pointer(minCS.ptr), minCS.length))
+	maxStr := string(cre2.CopyCBytes(unsafe.Pointer(maxCS.ptr), maxCS.length))
+	return minStr, maxStr, true
 }
 
 func readMatch(abi *libre2ABI, cs cString, matchPtr uintptr, dstCap []int) []int {
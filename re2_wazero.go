@@ -3,6 +3,7 @@
 package re2
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
 	"encoding/binary"
@@ -16,40 +17,60 @@ import (
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
 )
 
 var (
-	errFailedWrite = errors.New("failed to read from wasm memory")
+	errFailedWrite = errors.New("failed to write to wasm memory")
 	errFailedRead  = errors.New("failed to read from wasm memory")
+
+	// errMissingExports is reported when instantiating a wasm/libcre2.so
+	// built from an older cre2 that lacks functions this package needs.
+	errMissingExports = errors.New("re2: wasm/libcre2.so lacks required exports, run mage updateLibs")
 )
 
 //go:embed wasm/libcre2.so
 var libre2 []byte
 
-var (
-	wasmRT       wazero.Runtime
-	wasmCompiled wazero.CompiledModule
-)
-
 type libre2ABI struct {
 	cre2New                   api.Function
 	cre2Delete                api.Function
 	cre2Match                 api.Function
 	cre2PartialMatch          api.Function
 	cre2FindAndConsume        api.Function
+	cre2MatchBatch            api.Function
+	cre2FindAll               api.Function
 	cre2NumCapturingGroups    api.Function
+	cre2ProgramSize           api.Function
+	cre2ReverseProgramSize    api.Function
+	cre2ProgramFanout         api.Function
 	cre2ErrorCode             api.Function
 	cre2ErrorArg              api.Function
 	cre2NamedGroupsIterNew    api.Function
 	cre2NamedGroupsIterNext   api.Function
 	cre2NamedGroupsIterDelete api.Function
 	cre2GlobalReplace         api.Function
+	cre2PossibleMatchRange    api.Function
 	cre2OptNew                api.Function
 	cre2OptDelete             api.Function
 	cre2OptSetLogErrors       api.Function
 	cre2OptSetLongestMatch    api.Function
 	cre2OptSetPosixSyntax     api.Function
 	cre2OptSetCaseSensitive   api.Function
+	cre2OptSetLiteral         api.Function
+	cre2OptSetNeverNL         api.Function
+	cre2OptSetDotNL           api.Function
+	cre2OptSetNeverCapture    api.Function
+	cre2OptSetPerlClasses     api.Function
+	cre2OptSetWordBoundary    api.Function
+	cre2OptSetOneLine         api.Function
+	cre2OptSetMaxMem          api.Function
+	cre2OptSetEncoding        api.Function
+	cre2SetNew                api.Function
+	cre2SetDelete             api.Function
+	cre2SetAdd                api.Function
+	cre2SetCompile            api.Function
+	cre2SetMatch              api.Function
 
 	malloc api.Function
 	free   api.Function
@@ -57,14 +78,204 @@ type libre2ABI struct {
 	wasmMemory api.Memory
 
 	mod api.Module
+	// rt is the runtime mod was instantiated in.
+	rt wazero.Runtime
+	// closed is set once mod is closed.
+	closed bool
 
 	memory sharedMemory
 	mu     sync.Mutex
+
+	// ctx is the context for calls matching text, which is only changed
+	// from the background context by context-aware methods.
+	ctx context.Context
+	// broken is set when a call failed and left the module unusable, because
+	// re2 ran out of memory or a context-aware operation closed the module.
+	broken bool
+
+	// instances holds Regexps compiled in other modules that have also been
+	// compiled in this one to run operations concurrently.
+	instances map[instanceKey]*Regexp
+
+	// pending holds functions releasing memory that were passed to whenFree
+	// while the module was busy.
+	pendingMu sync.Mutex
+	pending   []func()
+}
+
+// wasmPageSize is the size of a page of WebAssembly memory, the unit of its
+// limit.
+const wasmPageSize = 65536
+
+// wasmRuntime holds the runtime the modules of abiPool are instantiated in. It
+// is created on first use with the configuration set by ConfigureRuntime, and
+// again for modules instantiated after the configuration changes.
+var wasmRuntime struct {
+	mu       sync.Mutex
+	config   RuntimeConfig
+	cache    wazero.CompilationCache
+	rt       wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+func configureRuntime(cfg RuntimeConfig) error {
+	var cache wazero.CompilationCache
+	if cfg.CompilationCacheDir != "" {
+		c, err := wazero.NewCompilationCacheWithDir(cfg.CompilationCacheDir)
+		if err != nil {
+			return err
+		}
+		cache = c
+	}
+
+	wasmRuntime.mu.Lock()
+	wasmRuntime.config = cfg
+	wasmRuntime.cache = cache
+	wasmRuntime.mu.Unlock()
+
+	resetRuntimes()
+	return nil
+}
+
+func memoryLimitChanged() {
+	resetRuntimes()
+}
+
+// resetRuntimes makes new modules be instantiated in new runtimes, created
+// with the current configuration. Existing modules of abiPool keep running in
+// the runtime they were instantiated in, which is closed along with the last
+// of them. Idle modules of contextPool only hold copies of expressions, so
+// they are closed right away.
+func resetRuntimes() {
+	wasmRuntime.mu.Lock()
+	rt := wasmRuntime.rt
+	wasmRuntime.rt = nil
+	wasmRuntime.compiled = nil
+	wasmRuntime.mu.Unlock()
+	runtimeReplaced(rt)
+
+	contextPool.mu.Lock()
+	rt = contextPool.rt
+	contextPool.rt = nil
+	contextPool.compiled = nil
+	for _, abi := range contextPool.idle {
+		abi.mu.Lock()
+		abi.closeModule()
+		abi.mu.Unlock()
+		removeContextABI(abi)
+	}
+	contextPool.idle = nil
+	contextPool.mu.Unlock()
+	runtimeReplaced(rt)
+}
+
+// runtimeModules counts the open modules of each runtime, so that a runtime
+// replaced by resetRuntimes can be closed once none of its modules are left.
+var runtimeModules struct {
+	mu       sync.Mutex
+	open     map[wazero.Runtime]int
+	replaced map[wazero.Runtime]bool
+}
+
+// moduleOpened records a module instantiated in rt.
+func moduleOpened(rt wazero.Runtime) {
+	runtimeModules.mu.Lock()
+	defer runtimeModules.mu.Unlock()
+
+	if runtimeModules.open == nil {
+		runtimeModules.open = map[wazero.Runtime]int{}
+	}
+	runtimeModules.open[rt]++
+}
+
+// moduleClosed records a closed module of rt, closing rt if it was replaced
+// and this was its last module.
+func moduleClosed(rt wazero.Runtime) {
+	runtimeModules.mu.Lock()
+	defer runtimeModules.mu.Unlock()
+
+	runtimeModules.open[rt]--
+	if runtimeModules.open[rt] > 0 {
+		return
+	}
+	delete(runtimeModules.open, rt)
+	if runtimeModules.replaced[rt] {
+		delete(runtimeModules.replaced, rt)
+		_ = rt.Close(context.Background())
+	}
+}
+
+// runtimeReplaced records that no more modules are instantiated in rt, which
+// may be nil, closing it if it has no open modules.
+func runtimeReplaced(rt wazero.Runtime) {
+	if rt == nil {
+		return
+	}
+
+	runtimeModules.mu.Lock()
+	defer runtimeModules.mu.Unlock()
+
+	if runtimeModules.open[rt] == 0 {
+		_ = rt.Close(context.Background())
+		return
+	}
+	if runtimeModules.replaced == nil {
+		runtimeModules.replaced = map[wazero.Runtime]bool{}
+	}
+	runtimeModules.replaced[rt] = true
+}
+
+// compiledLibre2 returns the runtime to instantiate modules of abiPool in and
+// the re2 module compiled in it, creating them on first use.
+func compiledLibre2() (wazero.Runtime, wazero.CompiledModule) {
+	wasmRuntime.mu.Lock()
+	defer wasmRuntime.mu.Unlock()
+
+	return compiledLibre2Locked()
+}
+
+// compiledLibre2Locked is compiledLibre2 with wasmRuntime locked.
+func compiledLibre2Locked() (wazero.Runtime, wazero.CompiledModule) {
+	if wasmRuntime.rt == nil {
+		cfg := wazeroConfig(wasmRuntime.config)
+		if wasmRuntime.cache != nil {
+			cfg = cfg.WithCompilationCache(wasmRuntime.cache)
+		}
+		wasmRuntime.rt, wasmRuntime.compiled = newRuntime(cfg)
+	}
+	return wasmRuntime.rt, wasmRuntime.compiled
+}
+
+// contextRuntimeConfig returns the configuration for the runtime of
+// contextPool. The compilation cache is not used, as it does not tell apart
+// code compiled to stop when the context is done.
+func contextRuntimeConfig() wazero.RuntimeConfig {
+	wasmRuntime.mu.Lock()
+	defer wasmRuntime.mu.Unlock()
+
+	return wazeroConfig(wasmRuntime.config).WithCloseOnContextDone(true)
 }
 
-func init() {
+func wazeroConfig(cfg RuntimeConfig) wazero.RuntimeConfig {
+	if cfg.Interpreter {
+		return wazero.NewRuntimeConfigInterpreter()
+	}
+	return wazero.NewRuntimeConfig()
+}
+
+// newRuntime creates a runtime with cfg and the memory limit set with
+// SetMemoryLimit, returning it with the re2 module compiled in it.
+func newRuntime(cfg wazero.RuntimeConfig) (wazero.Runtime, wazero.CompiledModule) {
 	ctx := context.Background()
-	rt := wazero.NewRuntime(ctx)
+
+	if limit := atomic.LoadInt64(&memoryLimit); limit > 0 {
+		pages := (limit + wasmPageSize - 1) / wasmPageSize
+		if pages > 65536 {
+			pages = 65536
+		}
+		cfg = cfg.WithMemoryLimitPages(uint32(pages))
+	}
+	rt := wazero.NewRuntimeWithConfig(ctx, cfg)
 
 	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
 
@@ -72,17 +283,386 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
-	wasmCompiled = code
+	return rt, code
+}
+
+// errBroken is reported for operations on Regexps and Sets that can only run
+// in a module that has run out of memory.
+var errBroken = fmt.Errorf("%w: module instance ran out of memory in an earlier operation", ErrMemoryLimit)
+
+// callError returns the error to panic with for a failed call into abi, which
+// leaves it unusable, so it is removed from abiPool. re2 traps when it cannot
+// allocate memory, which is reported as ErrMemoryLimit, while a module that
+// exited, such as one closed because its context is done, is left as is for
+// runContext.
+func (abi *libre2ABI) callError(err error) error {
+	abi.broken = true
+
+	// Expressions compiled in abi keep running in other modules.
+	abiPool.mu.Lock()
+	for i, a := range abiPool.abis {
+		if a == abi {
+			abiPool.abis = append(abiPool.abis[:i:i], abiPool.abis[i+1:]...)
+			break
+		}
+	}
+	abiPool.mu.Unlock()
+
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %v", ErrMemoryLimit, err)
+}
+
+// abiPool holds the module instances shared by all Regexps and Sets.
+// Instantiating a module has a large cost in memory and time compared to
+// compiling an expression, so it is not paid per Regexp. Each module can only
+// run one operation at a time, so up to concurrencyLimit are kept, both to
+// reduce contention between different Regexps and to allow running one Regexp
+// in several modules at once.
+var abiPool struct {
+	mu   sync.Mutex
+	abis []*libre2ABI
+	next int
+}
+
+// sharedABI returns a module instance to compile a new Regexp or Set in. The
+// pool grows up to concurrencyLimit instances, after which existing instances
+// are handed out in turn.
+func sharedABI() *libre2ABI {
+	abiPool.mu.Lock()
+	defer abiPool.mu.Unlock()
+
+	limit := concurrencyLimit()
+	if len(abiPool.abis) < limit {
+		abi := newABI()
+		abiPool.abis = append(abiPool.abis, abi)
+		return abi
+	}
+
+	abi := abiPool.abis[abiPool.next%limit]
+	abiPool.next = (abiPool.next + 1) % limit
+	return abi
+}
+
+// warmup creates the runtime and the first module of the pool.
+func warmup() {
+	abiPool.mu.Lock()
+	defer abiPool.mu.Unlock()
+
+	if len(abiPool.abis) == 0 {
+		abiPool.abis = append(abiPool.abis, newABI())
+	}
+}
+
+// freeABI returns a module instance from the pool that is not running an
+// operation, locked for one, instantiating a new one if all are busy and the
+// pool is below concurrencyLimit. It returns nil if none is available.
+func freeABI() *libre2ABI {
+	limit := concurrencyLimit()
+
+	abiPool.mu.Lock()
+	abis := abiPool.abis
+	abiPool.mu.Unlock()
+
+	if len(abis) > limit {
+		abis = abis[:limit]
+	}
+	for _, abi := range abis {
+		if abi.mu.TryLock() {
+			return abi
+		}
+	}
+
+	abiPool.mu.Lock()
+	defer abiPool.mu.Unlock()
 
-	wasmRT = rt
+	if len(abiPool.abis) >= limit {
+		return nil
+	}
+	abi := newABI()
+	abi.mu.Lock()
+	abiPool.abis = append(abiPool.abis, abi)
+	return abi
+}
+
+// instanceKey identifies a Regexp by the id of its handle, without referencing
+// it so that its instances in other modules do not keep it from being
+// garbage collected.
+type instanceKey uint64
+
+// startOperation locks a module instance to run an operation on re and
+// returns re as compiled in it. The module re was compiled in is used if it is
+// free, and otherwise any free module in the pool, compiling re in it the
+// first time, so that one Regexp can be matched from several goroutines at
+// once. If no module is free, it waits for the one re was compiled in.
+func startOperation(re *Regexp, memorySize int) *Regexp {
+	abi := re.abi
+	if abi.mu.TryLock() {
+		if !abi.broken {
+			return abi.prepareInstance(re, memorySize)
+		}
+		abi.mu.Unlock()
+	}
+
+	if abi := freeABI(); abi != nil {
+		return abi.prepareInstance(re, memorySize)
+	}
+
+	abi.mu.Lock()
+	if abi.broken {
+		// The expression can still be compiled in a module of the pool.
+		abi.mu.Unlock()
+		abi = sharedABI()
+		abi.mu.Lock()
+	}
+	return abi.prepareInstance(re, memorySize)
+}
+
+// prepareInstance prepares an operation on re in abi, which was just locked
+// for it, returning re as compiled in abi.
+func (abi *libre2ABI) prepareInstance(re *Regexp, memorySize int) *Regexp {
+	var inst *Regexp
+	abi.prepare(func() {
+		inst = abi.instance(re)
+		abi.memory.reserve(abi, uint32(memorySize))
+	})
+	return inst
+}
+
+// instance returns re as compiled in abi, which must be locked, compiling it
+// the first time.
+func (abi *libre2ABI) instance(re *Regexp) *Regexp {
+	if abi == re.abi {
+		return re
+	}
+
+	key := instanceKey(re.id)
+	if inst, ok := abi.instances[key]; ok {
+		return inst
+	}
+
+	abi.memory.reserve(abi, uint32(len(re.expr)+2))
+	cs := newCString(abi, re.expr)
+	rePtr := newRE(abi, cs, re.opts)
+	if errCode, errArg := reError(abi, rePtr); errCode != 0 {
+		// The expression compiled in re.abi, so this is a failure of abi,
+		// such as running out of memory, and it may compile another time.
+		deleteRE(abi, rePtr)
+		panic(fmt.Errorf("re2: compiling expression in another module instance: %w", newError(re.expr, errCode, errArg)))
+	}
+	inst := &Regexp{
+		handle:     &handle{ptr: rePtr, abi: abi},
+		opts:       re.opts,
+		expr:       re.expr,
+		numMatches: re.numMatches,
+	}
+	abi.instances[key] = inst
+	return inst
+}
+
+// releaseInstances deletes the expression of h from the modules other than
+// the one it was compiled in.
+func releaseInstances(h *handle) {
+	key := instanceKey(h.id)
+
+	abiPool.mu.Lock()
+	abis := abiPool.abis
+	abiPool.mu.Unlock()
+
+	contextPool.mu.Lock()
+	abis = append(append([]*libre2ABI(nil), abis...), contextPool.all...)
+	contextPool.mu.Unlock()
+
+	for _, abi := range abis {
+		if abi == h.abi {
+			continue
+		}
+		abi := abi
+		abi.whenFree(func() {
+			if inst, ok := abi.instances[key]; ok {
+				deleteRE(abi, inst.ptr)
+				delete(abi.instances, key)
+			}
+		})
+	}
+}
+
+// whenFree runs f with abi locked, right away if abi is free and otherwise
+// when it is next locked for an operation, so that releasing memory, such as
+// from a finalizer, never waits for operations in progress. f is not run if
+// abi becomes unusable, as its memory is then released all at once.
+func (abi *libre2ABI) whenFree(f func()) {
+	if !abi.mu.TryLock() {
+		abi.pendingMu.Lock()
+		abi.pending = append(abi.pending, f)
+		abi.pendingMu.Unlock()
+		// abi may have become free since trying to lock it, but will run f
+		// when next locked.
+		return
+	}
+	defer abi.mu.Unlock()
+	if !abi.broken {
+		f()
+	}
+}
+
+// runPending runs the functions passed to whenFree while abi was busy. abi
+// must be locked.
+func (abi *libre2ABI) runPending() {
+	abi.pendingMu.Lock()
+	pending := abi.pending
+	abi.pending = nil
+	abi.pendingMu.Unlock()
+
+	for _, f := range pending {
+		if abi.broken {
+			return
+		}
+		f()
+	}
+}
+
+// contextPool holds the module instances that run operations of
+// context-aware methods. They are instantiated in a separate runtime that
+// closes a module when the context of a call is done, which is the only way
+// to interrupt it, and otherwise has a cost for every call. A closed module
+// loses everything compiled in it, so these modules never hold anything
+// but instances of Regexps compiled in abiPool.
+var contextPool struct {
+	mu sync.Mutex
+	// rt is created on first use and after the memory limit changes.
+	rt       wazero.Runtime
+	compiled wazero.CompiledModule
+
+	all  []*libre2ABI
+	idle []*libre2ABI
+	// freed is closed and replaced whenever a module is returned to the pool.
+	freed chan struct{}
+}
+
+// acquireContextABI returns a module instance for an operation that stops
+// when ctx is done, waiting for one to be free if the pool has reached
+// concurrencyLimit.
+func acquireContextABI(ctx context.Context) (*libre2ABI, error) {
+	for {
+		contextPool.mu.Lock()
+		if contextPool.rt == nil {
+			contextPool.rt, contextPool.compiled = newRuntime(contextRuntimeConfig())
+		}
+		if contextPool.freed == nil {
+			contextPool.freed = make(chan struct{})
+		}
+		if n := len(contextPool.idle); n > 0 {
+			abi := contextPool.idle[n-1]
+			contextPool.idle = contextPool.idle[:n-1]
+			contextPool.mu.Unlock()
+			return abi, nil
+		}
+		if len(contextPool.all) < concurrencyLimit() {
+			abi := instantiateABI(contextPool.rt, contextPool.compiled)
+			contextPool.all = append(contextPool.all, abi)
+			contextPool.mu.Unlock()
+			return abi, nil
+		}
+		freed := contextPool.freed
+		contextPool.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// releaseContextABI returns abi to the pool after an operation, or drops it
+// if the operation left it unusable or its runtime was replaced.
+func releaseContextABI(abi *libre2ABI) {
+	contextPool.mu.Lock()
+	defer contextPool.mu.Unlock()
+
+	if abi.broken || abi.rt != contextPool.rt {
+		abi.mu.Lock()
+		abi.closeModule()
+		abi.mu.Unlock()
+		removeContextABI(abi)
+	} else {
+		contextPool.idle = append(contextPool.idle, abi)
+	}
+	close(contextPool.freed)
+	contextPool.freed = make(chan struct{})
+}
+
+// removeContextABI removes abi from contextPool.all. contextPool must be
+// locked.
+func removeContextABI(abi *libre2ABI) {
+	for i, a := range contextPool.all {
+		if a == abi {
+			contextPool.all = append(contextPool.all[:i], contextPool.all[i+1:]...)
+			break
+		}
+	}
+}
+
+// runContext runs op with re as compiled in a module instance that stops
+// running when ctx is done, returning ctx.Err() in that case.
+func runContext(re *Regexp, ctx context.Context, memorySize int, op func(inst *Regexp)) (err error) {
+	if ctx.Done() == nil {
+		// Can never be done, so there is no need for a separate module.
+		inst := startOperation(re, memorySize)
+		defer inst.abi.endOperation()
+		op(inst)
+		return nil
+	}
+
+	abi, err := acquireContextABI(ctx)
+	if err != nil {
+		return err
+	}
+
+	abi.mu.Lock()
+	defer func() {
+		r := recover()
+		if r != nil {
+			var exitErr *sys.ExitError
+			if e, ok := r.(error); ok && errors.As(e, &exitErr) && ctx.Err() != nil {
+				// The module was closed to stop the call.
+				err = ctx.Err()
+			}
+		}
+		abi.ctx = context.Background()
+		abi.mu.Unlock()
+		releaseContextABI(abi)
+		if r != nil && err == nil {
+			panic(r)
+		}
+	}()
+
+	abi.runPending()
+	inst := abi.instance(re)
+	abi.memory.reserve(abi, uint32(memorySize))
+	abi.ctx = ctx
+	op(inst)
+	return nil
 }
 
 var moduleIdx = uint64(0)
 
 func newABI() *libre2ABI {
+	// The runtime is not replaced and closed while instantiating in it.
+	wasmRuntime.mu.Lock()
+	defer wasmRuntime.mu.Unlock()
+
+	return instantiateABI(compiledLibre2Locked())
+}
+
+func instantiateABI(rt wazero.Runtime, code wazero.CompiledModule) *libre2ABI {
 	ctx := context.Background()
 	modIdx := atomic.AddUint64(&moduleIdx, 1)
-	mod, err := wasmRT.InstantiateModule(ctx, wasmCompiled, wazero.NewModuleConfig().WithName(strconv.FormatUint(modIdx, 10)))
+	mod, err := rt.InstantiateModule(ctx, code, wazero.NewModuleConfig().WithName(strconv.FormatUint(modIdx, 10)))
 	if err != nil {
 		panic(err)
 	}
@@ -93,75 +673,162 @@ func newABI() *libre2ABI {
 		cre2Match:                 mod.ExportedFunction("cre2_match"),
 		cre2PartialMatch:          mod.ExportedFunction("cre2_partial_match_re"),
 		cre2FindAndConsume:        mod.ExportedFunction("cre2_find_and_consume_re"),
+		cre2MatchBatch:            mod.ExportedFunction("cre2_match_batch"),
+		cre2FindAll:               mod.ExportedFunction("cre2_find_all"),
 		cre2NumCapturingGroups:    mod.ExportedFunction("cre2_num_capturing_groups"),
+		cre2ProgramSize:           mod.ExportedFunction("cre2_program_size"),
+		cre2ReverseProgramSize:    mod.ExportedFunction("cre2_reverse_program_size"),
+		cre2ProgramFanout:         mod.ExportedFunction("cre2_program_fanout"),
 		cre2ErrorCode:             mod.ExportedFunction("cre2_error_code"),
 		cre2ErrorArg:              mod.ExportedFunction("cre2_error_arg"),
 		cre2NamedGroupsIterNew:    mod.ExportedFunction("cre2_named_groups_iter_new"),
 		cre2NamedGroupsIterNext:   mod.ExportedFunction("cre2_named_groups_iter_next"),
 		cre2NamedGroupsIterDelete: mod.ExportedFunction("cre2_named_groups_iter_delete"),
 		cre2GlobalReplace:         mod.ExportedFunction("cre2_global_replace_re"),
+		cre2PossibleMatchRange:    mod.ExportedFunction("cre2_possible_match_range"),
 		cre2OptNew:                mod.ExportedFunction("cre2_opt_new"),
 		cre2OptDelete:             mod.ExportedFunction("cre2_opt_delete"),
 		cre2OptSetLogErrors:       mod.ExportedFunction("cre2_opt_set_log_errors"),
 		cre2OptSetLongestMatch:    mod.ExportedFunction("cre2_opt_set_longest_match"),
 		cre2OptSetPosixSyntax:     mod.ExportedFunction("cre2_opt_set_posix_syntax"),
 		cre2OptSetCaseSensitive:   mod.ExportedFunction("cre2_opt_set_case_sensitive"),
+		cre2OptSetLiteral:         mod.ExportedFunction("cre2_opt_set_literal"),
+		cre2OptSetNeverNL:         mod.ExportedFunction("cre2_opt_set_never_nl"),
+		cre2OptSetDotNL:           mod.ExportedFunction("cre2_opt_set_dot_nl"),
+		cre2OptSetNeverCapture:    mod.ExportedFunction("cre2_opt_set_never_capture"),
+		cre2OptSetPerlClasses:     mod.ExportedFunction("cre2_opt_set_perl_classes"),
+		cre2OptSetWordBoundary:    mod.ExportedFunction("cre2_opt_set_word_boundary"),
+		cre2OptSetOneLine:         mod.ExportedFunction("cre2_opt_set_one_line"),
+		cre2OptSetMaxMem:          mod.ExportedFunction("cre2_opt_set_max_mem"),
+		cre2OptSetEncoding:        mod.ExportedFunction("cre2_opt_set_encoding"),
+		cre2SetNew:                mod.ExportedFunction("cre2_set_new"),
+		cre2SetDelete:             mod.ExportedFunction("cre2_set_delete"),
+		cre2SetAdd:                mod.ExportedFunction("cre2_set_add"),
+		cre2SetCompile:            mod.ExportedFunction("cre2_set_compile"),
+		cre2SetMatch:              mod.ExportedFunction("cre2_set_match"),
 
 		malloc: mod.ExportedFunction("malloc"),
 		free:   mod.ExportedFunction("free"),
 
 		wasmMemory: mod.Memory(),
 		mod:        mod,
+		rt:         rt,
+
+		ctx:       context.Background(),
+		instances: map[instanceKey]*Regexp{},
 	}
 
+	if abi.cre2FindAll == nil {
+		_ = mod.Close(ctx)
+		panic(errMissingExports)
+	}
+
+	moduleOpened(rt)
 	return abi
 }
 
 func (abi *libre2ABI) startOperation(memorySize int) {
 	abi.mu.Lock()
-	abi.memory.reserve(abi, uint32(memorySize))
+	abi.prepare(func() {
+		if abi.broken {
+			panic(errBroken)
+		}
+		abi.memory.reserve(abi, uint32(memorySize))
+	})
+}
+
+// prepare runs f to prepare an operation abi was just locked for, unlocking
+// it if f panics, such as with ErrMemoryLimit, as the operation is then never
+// ended.
+func (abi *libre2ABI) prepare(f func()) {
+	prepared := false
+	defer func() {
+		if !prepared {
+			abi.mu.Unlock()
+		}
+	}()
+	abi.runPending()
+	f()
+	prepared = true
 }
 
 func (abi *libre2ABI) endOperation() {
+	if abi.broken {
+		// Nothing runs in it anymore, so release its memory.
+		abi.closeModule()
+	}
 	abi.mu.Unlock()
 }
 
-func newRE(abi *libre2ABI, pattern cString, longest bool, posix bool, caseInsensitive bool) uintptr {
+// closeModule closes the module of abi, which must be locked, once it is
+// unusable or no longer needed. Anything still compiled in it is lost.
+func (abi *libre2ABI) closeModule() {
+	abi.broken = true
+	if abi.closed {
+		return
+	}
+	abi.closed = true
+	_ = abi.mod.Close(context.Background())
+	moduleClosed(abi.rt)
+}
+
+func newRE(abi *libre2ABI, pattern cString, opts Options) uintptr {
 	ctx := context.Background()
 	res, err := abi.cre2OptNew.Call(ctx)
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
 	}
 	optPtr := uintptr(res[0])
 	defer func() {
 		if _, err := abi.cre2OptDelete.Call(ctx, uint64(optPtr)); err != nil {
-			panic(err)
+			panic(abi.callError(err))
 		}
 	}()
-	if _, err := abi.cre2OptSetLogErrors.Call(ctx, uint64(optPtr), 0); err != nil {
-		panic(err)
-	}
-	if longest {
-		_, err = abi.cre2OptSetLongestMatch.Call(ctx, uint64(optPtr), 1)
-		if err != nil {
-			panic(err)
+	setOpt := func(f api.Function, value uint64) {
+		if _, err := f.Call(ctx, uint64(optPtr), value); err != nil {
+			panic(abi.callError(err))
 		}
 	}
-	if posix {
-		_, err = abi.cre2OptSetPosixSyntax.Call(ctx, uint64(optPtr), 1)
-		if err != nil {
-			panic(err)
-		}
+	setOpt(abi.cre2OptSetLogErrors, 0)
+	if opts.Longest {
+		setOpt(abi.cre2OptSetLongestMatch, 1)
 	}
-	if caseInsensitive {
-		_, err = abi.cre2OptSetCaseSensitive.Call(ctx, uint64(optPtr), 0)
-		if err != nil {
-			panic(err)
-		}
+	if opts.POSIXSyntax {
+		setOpt(abi.cre2OptSetPosixSyntax, 1)
+	}
+	if opts.CaseInsensitive {
+		setOpt(abi.cre2OptSetCaseSensitive, 0)
+	}
+	if opts.Literal {
+		setOpt(abi.cre2OptSetLiteral, 1)
+	}
+	if opts.NeverNL {
+		setOpt(abi.cre2OptSetNeverNL, 1)
+	}
+	if opts.DotNL {
+		setOpt(abi.cre2OptSetDotNL, 1)
+	}
+	if opts.NeverCapture {
+		setOpt(abi.cre2OptSetNeverCapture, 1)
+	}
+	if opts.PerlClasses {
+		setOpt(abi.cre2OptSetPerlClasses, 1)
+	}
+	if opts.WordBoundary {
+		setOpt(abi.cre2OptSetWordBoundary, 1)
+	}
+	if opts.OneLine {
+		setOpt(abi.cre2OptSetOneLine, 1)
+	}
+	if opts.MaxMem > 0 {
+		setOpt(abi.cre2OptSetMaxMem, uint64(opts.MaxMem))
+	}
+	if opts.Encoding != EncodingUTF8 {
+		setOpt(abi.cre2OptSetEncoding, uint64(opts.Encoding.cre2()))
 	}
 	res, err = abi.cre2New.Call(ctx, uint64(pattern.ptr), uint64(pattern.length), uint64(optPtr))
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
 	}
 	return uintptr(res[0])
 }
@@ -170,7 +837,7 @@ func reError(abi *libre2ABI, rePtr uintptr) (int, string) {
 	ctx := context.Background()
 	res, err := abi.cre2ErrorCode.Call(ctx, uint64(rePtr))
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
 	}
 	code := int(res[0])
 	if code == 0 {
@@ -180,7 +847,7 @@ func reError(abi *libre2ABI, rePtr uintptr) (int, string) {
 	argPtr := newCStringArray(abi, 1)
 	_, err = abi.cre2ErrorArg.Call(ctx, uint64(rePtr), uint64(argPtr.ptr))
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
 	}
 	sPtr := binary.LittleEndian.Uint32(abi.memory.read(abi, argPtr.ptr, 4))
 	sLen := binary.LittleEndian.Uint32(abi.memory.read(abi, argPtr.ptr+4, 4))
@@ -192,46 +859,147 @@ func numCapturingGroups(abi *libre2ABI, rePtr uintptr) int {
 	ctx := context.Background()
 	res, err := abi.cre2NumCapturingGroups.Call(ctx, uint64(rePtr))
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
 	}
 	return int(res[0])
 }
 
-func deleteRE(abi *libre2ABI, rePtr uintptr) {
+func programSize(re *Regexp) int {
 	ctx := context.Background()
-	if _, err := abi.cre2Delete.Call(ctx, uint64(rePtr)); err != nil {
-		panic(err)
+	res, err := re.abi.cre2ProgramSize.Call(ctx, uint64(re.ptr))
+	if err != nil {
+		panic(re.abi.callError(err))
 	}
+	return int(int32(res[0]))
 }
 
-func release(re *Regexp) {
+func reverseProgramSize(re *Regexp) int {
 	ctx := context.Background()
-	deleteRE(re.abi, re.ptr)
-	if err := re.abi.mod.Close(ctx); err != nil {
-		fmt.Printf("error closing wazero module: %v", err)
+	res, err := re.abi.cre2ReverseProgramSize.Call(ctx, uint64(re.ptr))
+	if err != nil {
+		panic(re.abi.callError(err))
 	}
+	return int(int32(res[0]))
 }
 
-func match(re *Regexp, s cString, matchesPtr uintptr, nMatches uint32) bool {
+func programFanout(re *Regexp) []int {
 	ctx := context.Background()
-	res, err := re.abi.cre2Match.Call(ctx, uint64(re.ptr), uint64(s.ptr), uint64(s.length), 0, uint64(s.length), 0, uint64(matchesPtr), uint64(nMatches))
+	histPtr := re.abi.memory.allocate(4 * fanoutBuckets)
+	res, err := re.abi.cre2ProgramFanout.Call(ctx, uint64(re.ptr), uint64(histPtr), fanoutBuckets)
 	if err != nil {
-		panic(err)
+		panic(re.abi.callError(err))
 	}
 
-	return res[0] == 1
+	n := int(int32(res[0]))
+	buf := re.abi.memory.read(re.abi, histPtr, 4*n)
+	histogram := make([]int, n)
+	for i := range histogram {
+		histogram[i] = int(int32(binary.LittleEndian.Uint32(buf[4*i:])))
+	}
+	return histogram
 }
 
-func matchFrom(re *Regexp, s cString, startPos int, matchesPtr uintptr, nMatches uint32) bool {
+func deleteRE(abi *libre2ABI, rePtr uintptr) {
 	ctx := context.Background()
-	res, err := re.abi.cre2Match.Call(ctx, uint64(re.ptr), uint64(s.ptr), uint64(s.length), uint64(startPos), uint64(s.length), 0, uint64(matchesPtr), uint64(nMatches))
+	if _, err := abi.cre2Delete.Call(ctx, uint64(rePtr)); err != nil {
+		panic(abi.callError(err))
+	}
+}
+
+func release(h *handle) {
+	releaseInstances(h)
+
+	// The module is shared with other Regexps, which may be in use.
+	h.abi.whenFree(func() {
+		deleteRE(h.abi, h.ptr)
+	})
+}
+
+func match(re *Regexp, s cString, anchor int, matchesPtr uintptr, nMatches uint32) bool {
+	ctx := re.abi.ctx
+	res, err := re.abi.cre2Match.Call(ctx, uint64(re.ptr), uint64(s.ptr), uint64(s.length), 0, uint64(s.length), uint64(anchor), uint64(matchesPtr), uint64(nMatches))
 	if err != nil {
-		panic(err)
+		panic(re.abi.callError(err))
+	}
+
+	return res[0] == 1
+}
+
+func matchFrom(re *Regexp, s cString, startPos int, endPos int, matchesPtr uintptr, nMatches uint32) bool {
+	ctx := re.abi.ctx
+	res, err := re.abi.cre2Match.Call(ctx, uint64(re.ptr), uint64(s.ptr), uint64(s.length), uint64(startPos), uint64(endPos), 0, uint64(matchesPtr), uint64(nMatches))
+	if err != nil {
+		panic(re.abi.callError(err))
 	}
 
 	return res[0] == 1
 }
 
+func matchBatch(re *Regexp, texts []cString, anchor int, matchesPtr uintptr, nMatches uint32, deliver func(i int, matchPtr uintptr)) {
+	ctx := re.abi.ctx
+	buf := make([]byte, 8*len(texts))
+	for i, cs := range texts {
+		binary.LittleEndian.PutUint32(buf[8*i:], uint32(cs.ptr))
+		binary.LittleEndian.PutUint32(buf[8*i+4:], uint32(cs.length))
+	}
+	textsPtr := re.abi.memory.write(re.abi, buf)
+
+	matchedPtr := re.abi.memory.allocate(uint32(4 * len(texts)))
+	res, err := re.abi.cre2MatchBatch.Call(ctx, uint64(re.ptr), uint64(textsPtr), uint64(len(texts)), uint64(anchor), uint64(matchedPtr), uint64(matchesPtr), uint64(nMatches))
+	if err != nil {
+		panic(re.abi.callError(err))
+	}
+	if res[0] == 0 {
+		return
+	}
+
+	matched := re.abi.memory.read(re.abi, matchedPtr, 4*len(texts))
+	for i := range texts {
+		if binary.LittleEndian.Uint32(matched[4*i:]) != 0 {
+			deliver(i, matchesPtr+uintptr(8*int(nMatches)*i))
+		}
+	}
+}
+
+// findAllState is where re2 keeps the position of a search for all matches
+// between calls.
+type findAllState struct {
+	ptr uintptr
+}
+
+func newFindAllState(abi *libre2ABI, pos int, prevMatchEnd int) findAllState {
+	state := findAllState{ptr: abi.memory.allocate(8)}
+	state.write(abi, pos, prevMatchEnd)
+	return state
+}
+
+func (s findAllState) read(abi *libre2ABI) (pos int, prevMatchEnd int) {
+	buf := abi.memory.read(abi, s.ptr, 8)
+	return int(int32(binary.LittleEndian.Uint32(buf))), int(int32(binary.LittleEndian.Uint32(buf[4:])))
+}
+
+func (s findAllState) write(abi *libre2ABI, pos int, prevMatchEnd int) {
+	if !abi.wasmMemory.WriteUint32Le(uint32(s.ptr), uint32(int32(pos))) {
+		panic(errFailedWrite)
+	}
+	if !abi.wasmMemory.WriteUint32Le(uint32(s.ptr+4), uint32(int32(prevMatchEnd))) {
+		panic(errFailedWrite)
+	}
+}
+
+func findAllFrom(re *Regexp, cs cString, state findAllState, utf8 bool, matchesPtr uintptr, nMatches int, maxMatches int) int {
+	ctx := re.abi.ctx
+	utf8Flag := uint64(0)
+	if utf8 {
+		utf8Flag = 1
+	}
+	res, err := re.abi.cre2FindAll.Call(ctx, uint64(re.ptr), uint64(cs.ptr), uint64(cs.length), uint64(state.ptr), utf8Flag, uint64(matchesPtr), uint64(nMatches), uint64(maxMatches))
+	if err != nil {
+		panic(re.abi.callError(err))
+	}
+	return int(int32(res[0]))
+}
+
 func readMatch(abi *libre2ABI, cs cString, matchPtr uintptr, dstCap []int) []int {
 	matchBuf := abi.memory.read(abi, matchPtr, 8)
 	subStrPtr := uintptr(binary.LittleEndian.Uint32(matchBuf))
@@ -262,7 +1030,7 @@ func namedGroupsIter(abi *libre2ABI, rePtr uintptr) uintptr {
 
 	res, err := abi.cre2NamedGroupsIterNew.Call(ctx, uint64(rePtr))
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
 	}
 
 	return uintptr(res[0])
@@ -279,7 +1047,7 @@ func namedGroupsIterNext(abi *libre2ABI, iterPtr uintptr) (string, int, bool) {
 
 	res, err := abi.cre2NamedGroupsIterNext.Call(ctx, uint64(iterPtr), uint64(namePtrPtr), uint64(indexPtr))
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
 	}
 
 	if res[0] == 0 {
@@ -318,25 +1086,21 @@ func namedGroupsIterDelete(abi *libre2ABI, iterPtr uintptr) {
 
 	_, err := abi.cre2NamedGroupsIterDelete.Call(ctx, uint64(iterPtr))
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
 	}
 }
 
-func globalReplace(re *Regexp, textAndTargetPtr uintptr, rewritePtr uintptr) ([]byte, bool) {
-	ctx := context.Background()
+func globalReplace(re *Regexp, textAndTargetPtr uintptr, rewritePtr uintptr) ([]byte, int) {
+	ctx := re.abi.ctx
 
 	res, err := re.abi.cre2GlobalReplace.Call(ctx, uint64(re.ptr), uint64(textAndTargetPtr), uint64(rewritePtr))
 	if err != nil {
-		panic(err)
+		panic(re.abi.callError(err))
 	}
 
-	if int64(res[0]) == -1 {
-		panic("out of memory")
-	}
-
-	if res[0] == 0 {
-		// No replacements
-		return nil, false
+	count := int(int32(res[0]))
+	if count == -1 {
+		panic(ErrMemoryLimit)
 	}
 
 	strPtr, ok := re.abi.wasmMemory.ReadUint32Le(uint32(textAndTargetPtr))
@@ -346,6 +1110,11 @@ func globalReplace(re *Regexp, textAndTargetPtr uintptr, rewritePtr uintptr) ([]
 	// This was malloc'd by cre2, so free it
 	defer free(re.abi, uintptr(strPtr))
 
+	if count == 0 {
+		// No replacements
+		return nil, 0
+	}
+
 	strLen, ok := re.abi.wasmMemory.ReadUint32Le(uint32(textAndTargetPtr + 4))
 	if !ok {
 		panic(errFailedRead)
@@ -357,7 +1126,134 @@ func globalReplace(re *Regexp, textAndTargetPtr uintptr, rewritePtr uintptr) ([]
 	}
 
 	// Read returns a view, so make sure to copy it
-	return append([]byte{}, str...), true
+	return append([]byte{}, str...), count
+}
+
+func possibleMatchRange(re *Regexp, rangePtr uintptr, maxLen int) (string, string, bool) {
+	ctx := context.Background()
+
+	res, err := re.abi.cre2PossibleMatchRange.Call(ctx, uint64(re.ptr), uint64(rangePtr), uint64(rangePtr+8), uint64(maxLen))
+	if err != nil {
+		panic(re.abi.callError(err))
+	}
+
+	if int64(int32(res[0])) == -1 {
+		panic(ErrMemoryLimit)
+	}
+
+	if res[0] == 0 {
+		return "", "", false
+	}
+
+	rangeBuf := re.abi.memory.read(re.abi, rangePtr, 16)
+	minPtr := binary.LittleEndian.Uint32(rangeBuf)
+	minLen := binary.LittleEndian.Uint32(rangeBuf[4:])
+	maxPtr := binary.LittleEndian.Uint32(rangeBuf[8:])
+	maxLen32 := binary.LittleEndian.Uint32(rangeBuf[12:])
+	// These were malloc'd by cre2, so free them
+	defer free(re.abi, uintptr(minPtr))
+	defer free(re.abi, uintptr(maxPtr))
+
+	minStr, ok := re.abi.wasmMemory.Read(minPtr, minLen)
+	if !ok {
+		panic(errFailedRead)
+	}
+	maxStr, ok := re.abi.wasmMemory.Read(maxPtr, maxLen32)
+	if !ok {
+		panic(errFailedRead)
+	}
+
+	// Read returns a view, so make sure to copy it
+	return string(minStr), string(maxStr), true
+}
+
+func newSet(abi *libre2ABI, anchor int) uintptr {
+	ctx := context.Background()
+	res, err := abi.cre2OptNew.Call(ctx)
+	if err != nil {
+		panic(abi.callError(err))
+	}
+	optPtr := uintptr(res[0])
+	defer func() {
+		if _, err := abi.cre2OptDelete.Call(ctx, uint64(optPtr)); err != nil {
+			panic(abi.callError(err))
+		}
+	}()
+	if _, err := abi.cre2OptSetLogErrors.Call(ctx, uint64(optPtr), 0); err != nil {
+		panic(abi.callError(err))
+	}
+	res, err = abi.cre2SetNew.Call(ctx, uint64(optPtr), uint64(anchor))
+	if err != nil {
+		panic(abi.callError(err))
+	}
+	return uintptr(res[0])
+}
+
+func deleteSet(abi *libre2ABI, setPtr uintptr) {
+	ctx := context.Background()
+	if _, err := abi.cre2SetDelete.Call(ctx, uint64(setPtr)); err != nil {
+		panic(abi.callError(err))
+	}
+}
+
+func releaseSet(set *Set) {
+	// The module is shared with Regexps, which may be in use.
+	abi, ptr := set.abi, set.ptr
+	abi.whenFree(func() {
+		deleteSet(abi, ptr)
+	})
+}
+
+func setAdd(set *Set, pattern cString, errLen int) (int, string) {
+	ctx := context.Background()
+	errPtr := set.abi.memory.allocate(uint32(errLen))
+	res, err := set.abi.cre2SetAdd.Call(ctx, uint64(set.ptr), uint64(pattern.ptr), uint64(pattern.length), uint64(errPtr), uint64(errLen))
+	if err != nil {
+		panic(set.abi.callError(err))
+	}
+	idx := int(int32(res[0]))
+	if idx >= 0 {
+		return idx, ""
+	}
+
+	// C-string, read content until NULL.
+	msg := set.abi.memory.read(set.abi, errPtr, errLen)
+	if n := bytes.IndexByte(msg, 0); n >= 0 {
+		msg = msg[:n]
+	}
+	return idx, string(msg)
+}
+
+func setCompile(set *Set) bool {
+	ctx := context.Background()
+	res, err := set.abi.cre2SetCompile.Call(ctx, uint64(set.ptr))
+	if err != nil {
+		panic(set.abi.callError(err))
+	}
+	return res[0] == 1
+}
+
+func setMatch(set *Set, cs cString, n int) []int {
+	ctx := context.Background()
+	matchesPtr := set.abi.memory.allocate(uint32(4 * n))
+	res, err := set.abi.cre2SetMatch.Call(ctx, uint64(set.ptr), uint64(cs.ptr), uint64(cs.length), uint64(matchesPtr), uint64(n))
+	if err != nil {
+		panic(set.abi.callError(err))
+	}
+	count := int(res[0])
+	if count == 0 {
+		return nil
+	}
+	if count > n {
+		count = n
+	}
+
+	matchesBuf := set.abi.memory.read(set.abi, matchesPtr, 4*count)
+	matches := make([]int, count)
+	for i := range matches {
+		matches[i] = int(int32(binary.LittleEndian.Uint32(matchesBuf[4*i:])))
+	}
+	return matches
 }
 
 type cString struct {
@@ -381,6 +1277,51 @@ func newCStringFromBytes(abi *libre2ABI, s []byte) cString {
 	}
 }
 
+// residentString is a copy of a string kept in a module instance across
+// operations, so that a sequence of operations on it copies it only once.
+type residentString struct {
+	abi *libre2ABI
+	cs  cString
+}
+
+// newResidentString copies s into a module instance re can run in, where it
+// stays until released.
+func newResidentString(re *Regexp, s string) *residentString {
+	inst := re.startOperation(0)
+	defer inst.abi.endOperation()
+
+	// The copy is never at address zero, which readMatches takes as no match,
+	// even for an empty string.
+	size := uint32(len(s))
+	if size == 0 {
+		size = 1
+	}
+	ptr := malloc(inst.abi, size)
+	inst.abi.wasmMemory.WriteString(uint32(ptr), s)
+	return &residentString{abi: inst.abi, cs: cString{ptr: ptr, length: len(s)}}
+}
+
+// startOperation prepares an operation on re with s in the module instance
+// holding s, returning re as compiled there. ok is false if the module has
+// become unusable, in which case s must be copied again.
+func (s *residentString) startOperation(re *Regexp, memorySize int) (inst *Regexp, ok bool) {
+	s.abi.mu.Lock()
+	if s.abi.broken {
+		s.abi.mu.Unlock()
+		return nil, false
+	}
+	return s.abi.prepareInstance(re, memorySize), true
+}
+
+// release frees the copy of s.
+func (s *residentString) release() {
+	s.abi.mu.Lock()
+	defer s.abi.endOperation()
+	if !s.abi.broken {
+		free(s.abi, s.cs.ptr)
+	}
+}
+
 func newCStringPtr(abi *libre2ABI, cs cString) pointer {
 	ptr := abi.memory.allocate(8)
 	if !abi.wasmMemory.WriteUint32Le(uint32(ptr), uint32(cs.ptr)) {
@@ -409,7 +1350,10 @@ type pointer struct {
 func malloc(abi *libre2ABI, size uint32) uintptr {
 	res, err := abi.malloc.Call(context.Background(), uint64(size))
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
+	}
+	if res[0] == 0 {
+		panic(ErrMemoryLimit)
 	}
 	return uintptr(res[0])
 }
@@ -417,7 +1361,7 @@ func malloc(abi *libre2ABI, size uint32) uintptr {
 func free(abi *libre2ABI, ptr uintptr) {
 	_, err := abi.free.Call(context.Background(), uint64(ptr))
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
 	}
 }
 
@@ -437,13 +1381,18 @@ func (m *sharedMemory) reserve(abi *libre2ABI, size uint32) {
 	if m.bufPtr != 0 {
 		_, err := abi.free.Call(ctx, uint64(m.bufPtr))
 		if err != nil {
-			panic(err)
+			panic(abi.callError(err))
 		}
+		m.size = 0
+		m.bufPtr = 0
 	}
 
 	res, err := abi.malloc.Call(ctx, uint64(size))
 	if err != nil {
-		panic(err)
+		panic(abi.callError(err))
+	}
+	if res[0] == 0 {
+		panic(ErrMemoryLimit)
 	}
 
 	m.size = size
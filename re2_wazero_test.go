@@ -0,0 +1,413 @@
+//go:build !tinygo.wasm && !re2_cgo
+
+package re2
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestInstanceInOtherModule(t *testing.T) {
+	SetMaxConcurrency(2)
+	defer SetMaxConcurrency(0)
+
+	re := MustCompile(`a|ab`)
+	key := instanceKey(re.id)
+
+	// With the module re was compiled in busy, as if running an operation in
+	// another goroutine, re is compiled in another module to run.
+	re.abi.mu.Lock()
+	got := re.FindStringIndex("ab")
+	re.abi.mu.Unlock()
+	if want := []int{0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindStringIndex(%q) = %v, want %v", "ab", got, want)
+	}
+	if n := countInstances(key); n != 1 {
+		t.Fatalf("found %d instances, want 1", n)
+	}
+
+	// Longest must apply to the instance too.
+	re.Longest()
+	key = instanceKey(re.id)
+	re.abi.mu.Lock()
+	got = re.FindStringIndex("ab")
+	re.abi.mu.Unlock()
+	if want := []int{0, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindStringIndex(%q) after Longest = %v, want %v", "ab", got, want)
+	}
+
+	re.Close()
+	if n := countInstances(key); n != 0 {
+		t.Errorf("found %d instances after Close, want 0", n)
+	}
+}
+
+func TestReleaseWhileBusy(t *testing.T) {
+	SetMaxConcurrency(2)
+	defer SetMaxConcurrency(0)
+
+	re := MustCompile(`a|ab`)
+	key := instanceKey(re.id)
+	home := re.abi
+	home.mu.Lock()
+	re.FindStringIndex("ab")
+	home.mu.Unlock()
+
+	abiPool.mu.Lock()
+	abis := abiPool.abis
+	abiPool.mu.Unlock()
+	var other *libre2ABI
+	for _, abi := range abis {
+		abi.mu.Lock()
+		if _, ok := abi.instances[key]; ok {
+			other = abi
+		}
+		abi.mu.Unlock()
+	}
+	if other == nil {
+		t.Fatal("no instance in another module")
+	}
+
+	// Releasing does not wait for operations running in either module.
+	home.mu.Lock()
+	other.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		re.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		t.Fatal("Close waited for busy modules")
+	}
+	other.mu.Unlock()
+	home.mu.Unlock()
+	if n := countInstances(key); n != 1 {
+		t.Fatalf("found %d instances while busy, want 1", n)
+	}
+
+	// The instance is deleted when its module runs the next operation.
+	other.startOperation(0)
+	other.endOperation()
+	if n := countInstances(key); n != 0 {
+		t.Errorf("found %d instances after the next operation, want 0", n)
+	}
+}
+
+func TestInstanceCompileError(t *testing.T) {
+	re := MustCompile(`a`)
+	defer re.Close()
+
+	// An expression that fails to compile in another module, as it would
+	// when running out of memory there.
+	bad := &Regexp{
+		handle:     &handle{ptr: re.ptr, abi: re.abi, id: atomic.AddUint64(&handleIDs, 1)},
+		opts:       re.opts,
+		expr:       `(`,
+		numMatches: 1,
+	}
+
+	abi := newABI()
+	defer func() {
+		abi.mu.Lock()
+		abi.closeModule()
+		abi.mu.Unlock()
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("compiling the instance did not panic")
+			}
+		}()
+		abi.mu.Lock()
+		abi.prepareInstance(bad, 0)
+		abi.endOperation()
+	}()
+
+	abi.mu.Lock()
+	defer abi.mu.Unlock()
+	if n := len(abi.instances); n != 0 {
+		t.Errorf("found %d instances after failed compile, want 0", n)
+	}
+}
+
+func TestInstantiateMissingExports(t *testing.T) {
+	rt, _ := compiledLibre2()
+	// An empty module, lacking every function of wasm/libcre2.so.
+	code, err := rt.CompileModule(context.Background(), []byte("\x00asm\x01\x00\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if r := recover(); r != errMissingExports {
+			t.Errorf("instantiateABI panicked with %v, want %v", r, errMissingExports)
+		}
+	}()
+	instantiateABI(rt, code)
+}
+
+func countInstances(key instanceKey) int {
+	abiPool.mu.Lock()
+	abis := abiPool.abis
+	abiPool.mu.Unlock()
+
+	n := 0
+	for _, abi := range abis {
+		abi.mu.Lock()
+		if _, ok := abi.instances[key]; ok {
+			n++
+		}
+		abi.mu.Unlock()
+	}
+	return n
+}
+
+func TestContextInterrupt(t *testing.T) {
+	re := MustCompile(`(\w)`)
+	input := strings.Repeat("abcdefgh", 1<<20)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Long enough to still be running when the context is done.
+	_, err := re.FindAllStringSubmatchIndexContext(ctx, input, -1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FindAllStringSubmatchIndexContext returned error %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The closed module is replaced for later operations.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	got, err := re.FindStringIndexContext(ctx, "  ab")
+	if want := []int{2, 3}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("FindStringIndexContext after interrupt = (%v, %v), want (%v, nil)", got, err, want)
+	}
+	if got := re.FindStringIndex("  ab"); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("FindStringIndex after interrupt = %v, want %v", got, []int{2, 3})
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	SetMemoryLimit(4 << 20)
+	defer SetMemoryLimit(0)
+	withNewPool(t)
+
+	re := MustCompile(`a+b`)
+	defer re.Close()
+	input := strings.Repeat("a", 8<<20)
+
+	if _, err := re.MatchStringContext(context.Background(), input); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("MatchStringContext on large input returned error %v, want %v", err, ErrMemoryLimit)
+	}
+	func() {
+		defer func() {
+			r := recover()
+			if err, ok := r.(error); !ok || !errors.Is(err, ErrMemoryLimit) {
+				t.Errorf("MatchString on large input panicked with %v, want %v", r, ErrMemoryLimit)
+			}
+		}()
+		re.MatchString(input)
+	}()
+
+	// Running out of memory inside re2 leaves the module unusable, but
+	// expressions compiled in it keep working in a new one.
+	if _, err := CompileWithOptions(strings.Repeat(`(?:x[a-z]{1000})`, 200), Options{MaxMem: 1 << 30}); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("CompileWithOptions of large expression returned error %v, want %v", err, ErrMemoryLimit)
+	}
+	if !re.MatchString("aab") {
+		t.Errorf("MatchString(%q) after running out of memory = false, want true", "aab")
+	}
+	if re := MustCompile(`x+y`); !re.MatchString("xxy") {
+		t.Errorf("MatchString(%q) of new expression = false, want true", "xxy")
+	}
+
+	// Configuring the runtime keeps the limit.
+	if err := ConfigureRuntime(RuntimeConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	withNewPool(t)
+	if _, err := MustCompile(`a+b`).MatchStringContext(context.Background(), input); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("MatchStringContext after ConfigureRuntime returned error %v, want %v", err, ErrMemoryLimit)
+	}
+}
+
+func TestConfigureRuntime(t *testing.T) {
+	dir := t.TempDir()
+	if err := ConfigureRuntime(RuntimeConfig{Interpreter: true, CompilationCacheDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureRuntime(RuntimeConfig{})
+	withNewPool(t)
+
+	re := MustCompile(`a+b`)
+	defer re.Close()
+	if !re.MatchString("xaab") {
+		t.Errorf("MatchString(%q) = false, want true", "xaab")
+	}
+
+	// The interpreter has nothing to cache, unlike the compiler.
+	if runtime.GOARCH == "amd64" || runtime.GOARCH == "arm64" {
+		if err := ConfigureRuntime(RuntimeConfig{CompilationCacheDir: dir}); err != nil {
+			t.Fatal(err)
+		}
+		compiledLibre2()
+		if entries, _ := os.ReadDir(dir); len(entries) == 0 {
+			t.Errorf("found no entries in compilation cache directory")
+		}
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConfigureRuntime(RuntimeConfig{CompilationCacheDir: file}); err == nil {
+		t.Errorf("ConfigureRuntime with a file as cache directory returned no error")
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	// Start with no runtime, as when first importing the package.
+	resetRuntimes()
+	withNewPool(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Warmup(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Warmup with canceled context returned error %v, want %v", err, context.Canceled)
+	}
+
+	// Preparation still runs in the background.
+	for deadline := time.Now().Add(time.Minute); ; {
+		abiPool.mu.Lock()
+		n := len(abiPool.abis)
+		abiPool.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Warmup with canceled context did not prepare in the background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := Warmup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wasmRuntime.mu.Lock()
+	rt := wasmRuntime.rt
+	wasmRuntime.mu.Unlock()
+	if rt == nil {
+		t.Fatal("runtime not created by Warmup")
+	}
+	if n := len(abiPool.abis); n != 1 {
+		t.Errorf("found %d modules after Warmup, want 1", n)
+	}
+}
+
+func TestConcurrentFirstCompile(t *testing.T) {
+	resetRuntimes()
+	withNewPool(t)
+	SetMaxConcurrency(4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			re := MustCompile(`a+b`)
+			defer re.Close()
+			if !re.MatchString("xaab") {
+				t.Errorf("MatchString(%q) = false, want true", "xaab")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// withNewPool starts an empty pool of modules, to be instantiated with the
+// current runtime configuration, restoring the previous pool when t ends.
+func TestResetRuntimesClosesRuntime(t *testing.T) {
+	resetRuntimes()
+	withNewPool(t)
+
+	// A runtime without modules is closed right away.
+	unused, _ := compiledLibre2()
+	resetRuntimes()
+	if !runtimeClosed(unused) {
+		t.Error("replaced runtime without modules is not closed")
+	}
+
+	re := MustCompile(`a+`)
+	defer re.Close()
+	if _, err := re.MatchStringContext(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := re.MatchStringContext(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	contextPool.mu.Lock()
+	contextRT := contextPool.rt
+	contextPool.mu.Unlock()
+
+	resetRuntimes()
+
+	// The idle module of contextPool only held a copy of re, so its runtime
+	// is closed, while re keeps running in the module it was compiled in.
+	if !runtimeClosed(contextRT) {
+		t.Error("replaced runtime of contextPool is not closed")
+	}
+	if runtimeClosed(re.abi.rt) {
+		t.Fatal("runtime closed while a module is open")
+	}
+	if !re.MatchString("a") {
+		t.Error("MatchString after resetRuntimes = false, want true")
+	}
+
+	// The runtime is closed along with its last module.
+	rt := re.abi.rt
+	re.abi.mu.Lock()
+	_ = re.abi.callError(errors.New("broken"))
+	re.abi.endOperation()
+	if !runtimeClosed(rt) {
+		t.Error("replaced runtime is not closed after its last module")
+	}
+}
+
+// runtimeClosed reports whether rt was closed.
+func runtimeClosed(rt wazero.Runtime) bool {
+	_, err := rt.CompileModule(context.Background(), []byte("\x00asm\x01\x00\x00\x00"))
+	return err != nil
+}
+
+func withNewPool(t *testing.T) {
+	t.Helper()
+
+	SetMaxConcurrency(1)
+	abiPool.mu.Lock()
+	saved := abiPool.abis
+	abiPool.abis = nil
+	abiPool.mu.Unlock()
+
+	t.Cleanup(func() {
+		SetMaxConcurrency(0)
+		abiPool.mu.Lock()
+		abiPool.abis = saved
+		abiPool.mu.Unlock()
+	})
+}
@@ -0,0 +1,134 @@
+package re2
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrReaderLimit is returned by the Reader methods when the result cannot be
+// decided without reading more than the allowed number of bytes.
+var ErrReaderLimit = errors.New("re2: match not decided within reader limit")
+
+var errNegativeLimit = errors.New("re2: negative reader limit")
+
+// readerChunkSize is the initial size of the buffer for reading input.
+const readerChunkSize = 4096
+
+// MatchReaderLimit reports whether the text read from r contains any match of
+// the regular expression re. re2 does not support streaming input, so the text
+// is buffered, but only up to limit bytes. The buffered text is matched each
+// time the buffer grows, returning as soon as a match is found that cannot be
+// affected by further input. If the result is still undecided when limit bytes
+// have been buffered, ErrReaderLimit is returned. Errors returned by r other
+// than io.EOF are returned as is, and limit must not be negative.
+func (re *Regexp) MatchReaderLimit(r io.Reader, limit int) (bool, error) {
+	matched := false
+	err := readLimited(r, limit, func(b []byte, eof bool) bool {
+		loc := re.FindIndex(b)
+		if loc == nil {
+			// More input could still match.
+			return eof
+		}
+		// A match reaching the end of the buffer may not hold with more input,
+		// for example if it ends with $.
+		if eof || loc[1] < len(b) {
+			matched = true
+			return true
+		}
+		return false
+	})
+	return matched, err
+}
+
+// FindReaderIndexLimit returns a two-element slice of integers defining the
+// location of the leftmost match of the regular expression in text read from
+// r. The match text was found in the input stream at byte offset loc[0]
+// through loc[1]-1. A return value of nil indicates no match.
+//
+// Further input can change which match is leftmost, so the entire text is
+// buffered before matching. If r has more than limit bytes, ErrReaderLimit is
+// returned. Errors returned by r other than io.EOF are returned as is.
+func (re *Regexp) FindReaderIndexLimit(r io.Reader, limit int) (loc []int, err error) {
+	err = readLimited(r, limit, func(b []byte, eof bool) bool {
+		if eof {
+			loc = re.FindIndex(b)
+		}
+		return eof
+	})
+	return loc, err
+}
+
+// FindReaderSubmatchIndexLimit returns a slice holding the index pairs
+// identifying the leftmost match of the regular expression of text read from
+// r and the matches, if any, of its subexpressions, as defined by the
+// 'Submatch' and 'Index' descriptions in the package comment. A return value
+// of nil indicates no match.
+//
+// As with FindReaderIndexLimit, the entire text is buffered before matching.
+// If r has more than limit bytes, ErrReaderLimit is returned. Errors returned
+// by r other than io.EOF are returned as is.
+func (re *Regexp) FindReaderSubmatchIndexLimit(r io.Reader, limit int) (loc []int, err error) {
+	err = readLimited(r, limit, func(b []byte, eof bool) bool {
+		if eof {
+			loc = re.FindSubmatchIndex(b)
+		}
+		return eof
+	})
+	return loc, err
+}
+
+// readLimited reads r into a buffer of at most limit bytes, calling decide
+// with the buffered text each time the buffer fills up and once the end of r
+// is reached, signaled by eof. decide returns true once the result does not
+// depend on further input, which stops reading. ErrReaderLimit is only
+// returned if the buffer is full and r still has more input, so input of
+// exactly limit bytes is always decided.
+func readLimited(r io.Reader, limit int, decide func(b []byte, eof bool) bool) error {
+	if limit < 0 {
+		return errNegativeLimit
+	}
+
+	size := readerChunkSize
+	if size > limit {
+		size = limit
+	}
+	buf := make([]byte, 0, size)
+
+	for {
+		if len(buf) == cap(buf) {
+			if decide(buf, false) {
+				return nil
+			}
+			if len(buf) == limit {
+				// Full, but the reader may happen to be exhausted already.
+				var probe [1]byte
+				n, err := io.ReadFull(r, probe[:])
+				if n > 0 {
+					return ErrReaderLimit
+				}
+				if err != io.EOF {
+					return err
+				}
+				decide(buf, true)
+				return nil
+			}
+			size = 2 * cap(buf)
+			if size > limit {
+				size = limit
+			}
+			grown := make([]byte, len(buf), size)
+			copy(grown, buf)
+			buf = grown
+		}
+
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			decide(buf, true)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package re2
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReaderLimit(t *testing.T) {
+	for _, test := range findTests {
+		re := MustCompile(test.pat)
+
+		matched, err := re.MatchReaderLimit(iotest.OneByteReader(strings.NewReader(test.text)), len(test.text))
+		if err != nil {
+			t.Errorf("MatchReaderLimit: %s: unexpected error: %v", test, err)
+		} else if matched != (test.matches != nil) {
+			t.Errorf("MatchReaderLimit: %s: got %v", test, matched)
+		}
+
+		loc, err := re.FindReaderIndexLimit(strings.NewReader(test.text), len(test.text))
+		if err != nil {
+			t.Errorf("FindReaderIndexLimit: %s: unexpected error: %v", test, err)
+		} else if test.matches == nil && loc != nil {
+			t.Errorf("FindReaderIndexLimit: %s: expected no match; got one: %v", test, loc)
+		} else if test.matches != nil && !reflect.DeepEqual(loc, test.matches[0][:2]) {
+			t.Errorf("FindReaderIndexLimit: %s: expected %v; got %v", test, test.matches[0][:2], loc)
+		}
+
+		loc, err = re.FindReaderSubmatchIndexLimit(strings.NewReader(test.text), len(test.text))
+		if err != nil {
+			t.Errorf("FindReaderSubmatchIndexLimit: %s: unexpected error: %v", test, err)
+		} else if test.matches == nil && loc != nil {
+			t.Errorf("FindReaderSubmatchIndexLimit: %s: expected no match; got one: %v", test, loc)
+		} else if test.matches != nil && !reflect.DeepEqual(loc, test.matches[0]) {
+			t.Errorf("FindReaderSubmatchIndexLimit: %s: expected %v; got %v", test, test.matches[0], loc)
+		}
+	}
+}
+
+// repeatReader endlessly returns the same byte.
+type repeatReader byte
+
+func (r repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func TestReaderLimitExceeded(t *testing.T) {
+	limit := 3 * readerChunkSize
+
+	// A match ending before the end of the buffer is decided without reading all input.
+	matched, err := MustCompile(`a+b?`).MatchReaderLimit(io.MultiReader(strings.NewReader("xab"), repeatReader('c')), limit)
+	if err != nil || !matched {
+		t.Errorf("MatchReaderLimit = %v, %v; want true, nil", matched, err)
+	}
+
+	// A match at the end of the buffer could be changed by further input.
+	matched, err = MustCompile(`a+$`).MatchReaderLimit(repeatReader('a'), limit)
+	if !errors.Is(err, ErrReaderLimit) {
+		t.Errorf("MatchReaderLimit = %v, %v; want %v", matched, err, ErrReaderLimit)
+	}
+
+	if _, err := MustCompile(`b`).MatchReaderLimit(repeatReader('a'), limit); !errors.Is(err, ErrReaderLimit) {
+		t.Errorf("MatchReaderLimit error = %v; want %v", err, ErrReaderLimit)
+	}
+	if _, err := MustCompile(`a`).FindReaderIndexLimit(repeatReader('a'), limit); !errors.Is(err, ErrReaderLimit) {
+		t.Errorf("FindReaderIndexLimit error = %v; want %v", err, ErrReaderLimit)
+	}
+	if _, err := MustCompile(`(a)`).FindReaderSubmatchIndexLimit(repeatReader('a'), limit); !errors.Is(err, ErrReaderLimit) {
+		t.Errorf("FindReaderSubmatchIndexLimit error = %v; want %v", err, ErrReaderLimit)
+	}
+
+	// Input of exactly limit bytes is read fully.
+	text := strings.Repeat("a", limit-1) + "b"
+	loc, err := MustCompile(`a+b$`).FindReaderIndexLimit(strings.NewReader(text), limit)
+	if err != nil || !reflect.DeepEqual(loc, []int{0, limit}) {
+		t.Errorf("FindReaderIndexLimit = %v, %v; want %v, nil", loc, err, []int{0, limit})
+	}
+	matched, err = MustCompile(`a+b$`).MatchReaderLimit(strings.NewReader(text), limit)
+	if err != nil || !matched {
+		t.Errorf("MatchReaderLimit = %v, %v; want true, nil", matched, err)
+	}
+}
+
+func TestReaderLimitGreedyAtEOF(t *testing.T) {
+	// A greedy match reaching the end of input of exactly limit bytes is
+	// decided once the reader is exhausted, however it returns the input.
+	for _, limit := range []int{0, 1, readerChunkSize, readerChunkSize + 1, 2 * readerChunkSize} {
+		text := strings.Repeat("a", limit)
+		readers := map[string]func() io.Reader{
+			"Reader":        func() io.Reader { return strings.NewReader(text) },
+			"OneByteReader": func() io.Reader { return iotest.OneByteReader(strings.NewReader(text)) },
+			"DataErrReader": func() io.Reader { return iotest.DataErrReader(strings.NewReader(text)) },
+		}
+		for name, r := range readers {
+			if matched, err := MustCompile(`a*`).MatchReaderLimit(r(), limit); err != nil || !matched {
+				t.Errorf("MatchReaderLimit(%s of %d bytes) = %v, %v; want true, nil", name, limit, matched, err)
+			}
+			if matched, err := MustCompile(`a*$`).MatchReaderLimit(r(), limit); err != nil || !matched {
+				t.Errorf("MatchReaderLimit(%s of %d bytes) with $ = %v, %v; want true, nil", name, limit, matched, err)
+			}
+			want := []int{0, limit}
+			if loc, err := MustCompile(`a*`).FindReaderIndexLimit(r(), limit); err != nil || !reflect.DeepEqual(loc, want) {
+				t.Errorf("FindReaderIndexLimit(%s of %d bytes) = %v, %v; want %v, nil", name, limit, loc, err, want)
+			}
+		}
+	}
+}
+
+func TestReaderLimitNegative(t *testing.T) {
+	re := MustCompile(`a`)
+	if _, err := re.MatchReaderLimit(strings.NewReader("a"), -1); err == nil {
+		t.Errorf("MatchReaderLimit with limit -1: missing error")
+	}
+	if _, err := re.FindReaderIndexLimit(strings.NewReader("a"), -1); err == nil {
+		t.Errorf("FindReaderIndexLimit with limit -1: missing error")
+	}
+	if _, err := re.FindReaderSubmatchIndexLimit(strings.NewReader("a"), -1); err == nil {
+		t.Errorf("FindReaderSubmatchIndexLimit with limit -1: missing error")
+	}
+}
+
+func TestReaderLimitReadError(t *testing.T) {
+	errRead := errors.New("read error")
+	r := io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(errRead))
+	if _, err := MustCompile(`d`).MatchReaderLimit(r, 100); !errors.Is(err, errRead) {
+		t.Errorf("MatchReaderLimit error = %v; want %v", err, errRead)
+	}
+}
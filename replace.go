@@ -0,0 +1,158 @@
+package re2
+
+// ReplaceFirst returns a copy of src, replacing the first match of the Regexp
+// with the replacement text repl, and the number of replacements made, which
+// is 0 or 1. Inside repl, $ signs are interpreted as in Expand, so for
+// instance $1 represents the text of the first submatch.
+func (re *Regexp) ReplaceFirst(src, repl []byte) ([]byte, int) {
+	return re.ReplaceN(src, repl, 1)
+}
+
+// ReplaceFirstString returns a copy of src, replacing the first match of the
+// Regexp with the replacement string repl, and the number of replacements
+// made, which is 0 or 1. Inside repl, $ signs are interpreted as in Expand, so
+// for instance $1 represents the text of the first submatch.
+func (re *Regexp) ReplaceFirstString(src, repl string) (string, int) {
+	return re.ReplaceNString(src, repl, 1)
+}
+
+// ReplaceFirstLiteral returns a copy of src, replacing the first match of the
+// Regexp with the replacement bytes repl, and the number of replacements made,
+// which is 0 or 1. The replacement repl is substituted directly, without using
+// Expand.
+func (re *Regexp) ReplaceFirstLiteral(src, repl []byte) ([]byte, int) {
+	return re.ReplaceNLiteral(src, repl, 1)
+}
+
+// ReplaceFirstLiteralString returns a copy of src, replacing the first match
+// of the Regexp with the replacement string repl, and the number of
+// replacements made, which is 0 or 1. The replacement repl is substituted
+// directly, without using Expand.
+func (re *Regexp) ReplaceFirstLiteralString(src, repl string) (string, int) {
+	return re.ReplaceNLiteralString(src, repl, 1)
+}
+
+// ReplaceN returns a copy of src, replacing the first n matches of the Regexp
+// with the replacement text repl, and the number of replacements made. If n is
+// negative, all matches are replaced as with ReplaceAll. Inside repl, $ signs
+// are interpreted as in Expand, so for instance $1 represents the text of the
+// first submatch.
+func (re *Regexp) ReplaceN(src, repl []byte, n int) ([]byte, int) {
+	if n < 0 {
+		replRE2 := convertReplacement(string(repl), re.SubexpNames())
+		return re.replaceAllCount(src, "", replRE2)
+	}
+	res, count := re.replaceN(src, "", re.FindAllSubmatchIndex(src, n), func(dst []byte, match []int) []byte {
+		return re.Expand(dst, repl, src, match)
+	})
+	if count == 0 {
+		return src, 0
+	}
+	return res, count
+}
+
+// ReplaceNString returns a copy of src, replacing the first n matches of the
+// Regexp with the replacement string repl, and the number of replacements
+// made. If n is negative, all matches are replaced as with ReplaceAllString.
+// Inside repl, $ signs are interpreted as in Expand, so for instance $1
+// represents the text of the first submatch.
+func (re *Regexp) ReplaceNString(src, repl string, n int) (string, int) {
+	if n < 0 {
+		replRE2 := convertReplacement(repl, re.SubexpNames())
+		res, count := re.replaceAllCount(nil, src, replRE2)
+		if count == 0 {
+			return src, 0
+		}
+		return string(res), count
+	}
+	res, count := re.replaceN(nil, src, re.FindAllStringSubmatchIndex(src, n), func(dst []byte, match []int) []byte {
+		return re.ExpandString(dst, repl, src, match)
+	})
+	if count == 0 {
+		return src, 0
+	}
+	return string(res), count
+}
+
+// ReplaceNLiteral returns a copy of src, replacing the first n matches of the
+// Regexp with the replacement bytes repl, and the number of replacements made.
+// If n is negative, all matches are replaced as with ReplaceAllLiteral. The
+// replacement repl is substituted directly, without using Expand.
+func (re *Regexp) ReplaceNLiteral(src, repl []byte, n int) ([]byte, int) {
+	if n < 0 {
+		replRE2 := []byte(escapeReplacement(string(repl)))
+		return re.replaceAllCount(src, "", replRE2)
+	}
+	res, count := re.replaceN(src, "", re.FindAllIndex(src, n), func(dst []byte, _ []int) []byte {
+		return append(dst, repl...)
+	})
+	if count == 0 {
+		return src, 0
+	}
+	return res, count
+}
+
+// ReplaceNLiteralString returns a copy of src, replacing the first n matches
+// of the Regexp with the replacement string repl, and the number of
+// replacements made. If n is negative, all matches are replaced as with
+// ReplaceAllLiteralString. The replacement repl is substituted directly,
+// without using Expand.
+func (re *Regexp) ReplaceNLiteralString(src, repl string, n int) (string, int) {
+	if n < 0 {
+		replRE2 := []byte(escapeReplacement(repl))
+		res, count := re.replaceAllCount(nil, src, replRE2)
+		if count == 0 {
+			return src, 0
+		}
+		return string(res), count
+	}
+	res, count := re.replaceN(nil, src, re.FindAllStringIndex(src, n), func(dst []byte, _ []int) []byte {
+		return append(dst, repl...)
+	})
+	if count == 0 {
+		return src, 0
+	}
+	return string(res), count
+}
+
+// replaceAllCount replaces all matches in the input, which is bsrc if non-nil
+// and otherwise src, with repl in re2's rewrite syntax. If there are no
+// matches, the returned count is 0 and the input should be used as is.
+func (re *Regexp) replaceAllCount(bsrc []byte, src string, repl []byte) ([]byte, int) {
+	inst := re.startOperation(len(bsrc) + len(src) + len(repl) + 16)
+	defer inst.abi.endOperation()
+
+	srcCS := newCStringFromEither(inst.abi, bsrc, src)
+
+	res, count := inst.replaceAll(srcCS, repl)
+	if count == 0 {
+		return bsrc, 0
+	}
+	return res, count
+}
+
+// replaceN builds a copy of the input, which is bsrc if non-nil and otherwise
+// src, with each of matches replaced by what repl appends to dst.
+func (re *Regexp) replaceN(bsrc []byte, src string, matches [][]int, repl func(dst []byte, match []int) []byte) ([]byte, int) {
+	if len(matches) == 0 {
+		return nil, 0
+	}
+
+	var dst []byte
+	lastMatchEnd := 0
+	for _, match := range matches {
+		if bsrc != nil {
+			dst = append(dst, bsrc[lastMatchEnd:match[0]]...)
+		} else {
+			dst = append(dst, src[lastMatchEnd:match[0]]...)
+		}
+		dst = repl(dst, match)
+		lastMatchEnd = match[1]
+	}
+	if bsrc != nil {
+		dst = append(dst, bsrc[lastMatchEnd:]...)
+	} else {
+		dst = append(dst, src[lastMatchEnd:]...)
+	}
+	return dst, len(matches)
+}
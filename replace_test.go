@@ -0,0 +1,112 @@
+package re2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceN(t *testing.T) {
+	tests := []struct {
+		pattern, replacement, input string
+		n                           int
+		output                      string
+		count                       int
+	}{
+		{`a`, "<$0>", "banana", 0, "banana", 0},
+		{`a`, "<$0>", "banana", 1, "b<a>nana", 1},
+		{`a`, "<$0>", "banana", 2, "b<a>n<a>na", 2},
+		{`a`, "<$0>", "banana", 10, "b<a>n<a>n<a>", 3},
+		{`a`, "<$0>", "banana", -1, "b<a>n<a>n<a>", 3},
+		{`x`, "<$0>", "banana", 1, "banana", 0},
+		{`x`, "<$0>", "banana", -1, "banana", 0},
+		{`(\w+)@(\w+)`, "$2@$1", "x@y a@b", 1, "y@x a@b", 1},
+		{`(?P<user>\w+)@(?P<host>\w+)`, "${host}_$user", "x@y a@b", 2, "y_x b_a", 2},
+		{`a*`, "-", "baaac", 2, "-b-c", 2},
+		{`a*`, "-", "baaac", 3, "-b-c-", 3},
+	}
+
+	for _, tc := range tests {
+		re := MustCompile(tc.pattern)
+
+		output, count := re.ReplaceNString(tc.input, tc.replacement, tc.n)
+		if output != tc.output || count != tc.count {
+			t.Errorf("%q.ReplaceNString(%q, %q, %d) = (%q, %d), want (%q, %d)",
+				tc.pattern, tc.input, tc.replacement, tc.n, output, count, tc.output, tc.count)
+		}
+		boutput, count := re.ReplaceN([]byte(tc.input), []byte(tc.replacement), tc.n)
+		if string(boutput) != tc.output || count != tc.count {
+			t.Errorf("%q.ReplaceN(%q, %q, %d) = (%q, %d), want (%q, %d)",
+				tc.pattern, tc.input, tc.replacement, tc.n, boutput, count, tc.output, tc.count)
+		}
+
+		if tc.n != 1 {
+			continue
+		}
+		output, count = re.ReplaceFirstString(tc.input, tc.replacement)
+		if output != tc.output || count != tc.count {
+			t.Errorf("%q.ReplaceFirstString(%q, %q) = (%q, %d), want (%q, %d)",
+				tc.pattern, tc.input, tc.replacement, output, count, tc.output, tc.count)
+		}
+		boutput, count = re.ReplaceFirst([]byte(tc.input), []byte(tc.replacement))
+		if string(boutput) != tc.output || count != tc.count {
+			t.Errorf("%q.ReplaceFirst(%q, %q) = (%q, %d), want (%q, %d)",
+				tc.pattern, tc.input, tc.replacement, boutput, count, tc.output, tc.count)
+		}
+	}
+}
+
+func TestReplaceNLiteral(t *testing.T) {
+	re := MustCompile(`a`)
+
+	for _, n := range []int{1, -1} {
+		want, wantCount := "b$0nana", 1
+		if n < 0 {
+			want, wantCount = "b$0n$0n$0", 3
+		}
+
+		output, count := re.ReplaceNLiteralString("banana", "$0", n)
+		if output != want || count != wantCount {
+			t.Errorf("ReplaceNLiteralString(%d) = (%q, %d), want (%q, %d)", n, output, count, want, wantCount)
+		}
+		boutput, count := re.ReplaceNLiteral([]byte("banana"), []byte("$0"), n)
+		if string(boutput) != want || count != wantCount {
+			t.Errorf("ReplaceNLiteral(%d) = (%q, %d), want (%q, %d)", n, boutput, count, want, wantCount)
+		}
+	}
+
+	output, count := re.ReplaceFirstLiteralString("banana", `\0`)
+	if want := `b\0nana`; output != want || count != 1 {
+		t.Errorf("ReplaceFirstLiteralString = (%q, %d), want (%q, 1)", output, count, want)
+	}
+	boutput, count := re.ReplaceFirstLiteral([]byte("banana"), []byte(`\0`))
+	if want := `b\0nana`; string(boutput) != want || count != 1 {
+		t.Errorf("ReplaceFirstLiteral = (%q, %d), want (%q, 1)", boutput, count, want)
+	}
+}
+
+// Replacing as many matches as there are should agree with ReplaceAll.
+func TestReplaceNMatchesReplaceAll(t *testing.T) {
+	for _, tc := range replaceTests {
+		re, err := Compile(tc.pattern)
+		if err != nil {
+			t.Errorf("Unexpected error compiling %q: %v", tc.pattern, err)
+			continue
+		}
+		n := len(re.FindAllStringIndex(tc.input, -1))
+		if actual, _ := re.ReplaceNString(tc.input, tc.replacement, n); actual != tc.output {
+			t.Errorf("%q.ReplaceNString(%q,%q,%d) = %q; want %q",
+				tc.pattern, tc.input, tc.replacement, n, actual, tc.output)
+		}
+		if actual, count := re.ReplaceNString(tc.input, tc.replacement, -1); actual != tc.output || (count == 0) != (n == 0) {
+			t.Errorf("%q.ReplaceNString(%q,%q,-1) = (%q, %d); want %q",
+				tc.pattern, tc.input, tc.replacement, actual, count, tc.output)
+		}
+		if strings.Contains(tc.replacement, "$") {
+			continue
+		}
+		if actual, _ := re.ReplaceNLiteralString(tc.input, tc.replacement, n); actual != tc.output {
+			t.Errorf("%q.ReplaceNLiteralString(%q,%q,%d) = %q; want %q",
+				tc.pattern, tc.input, tc.replacement, n, actual, tc.output)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package re2
+
+import "context"
+
+// RuntimeConfig configures the WebAssembly runtime that runs re2 in the
+// default mode. The zero value is the default configuration.
+type RuntimeConfig struct {
+	// Interpreter runs re2 with the wazero interpreter instead of compiling it
+	// to machine code. The runtime is created faster, but matching is
+	// significantly slower. The interpreter is always used on platforms the
+	// compiler does not support.
+	Interpreter bool
+
+	// CompilationCacheDir is a directory to store the machine code compiled from
+	// re2 in, so that later processes using the same directory skip compiling
+	// it. It is created if it does not exist. If empty, nothing is stored.
+	CompilationCacheDir string
+}
+
+// ConfigureRuntime sets the configuration of the WebAssembly runtime that runs
+// re2 in the default mode. The runtime is created when it is first needed,
+// such as to compile an expression, so this should be called before then.
+// Calling it afterwards creates a new runtime for module instances created
+// after the call. The memory limit is set separately with SetMemoryLimit and
+// is kept. An error is returned if the compilation cache directory cannot be
+// created. With cgo or TinyGo, there is no WebAssembly runtime and this has
+// no effect.
+func ConfigureRuntime(cfg RuntimeConfig) error {
+	return configureRuntime(cfg)
+}
+
+// Warmup prepares the WebAssembly runtime that runs re2 in the default mode,
+// which otherwise happens when it is first needed, such as to compile an
+// expression. It compiles re2 to machine code and instantiates a module, which
+// can take significant time, so servers can call it in the background when
+// starting to not add that time to the first request. It returns ctx.Err() if
+// ctx is done first, including when it is already done on entry, leaving
+// preparation to continue in the background. With cgo or TinyGo, there is
+// nothing to prepare and it returns immediately.
+func Warmup(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		warmup()
+		close(done)
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,170 @@
+package re2
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync/atomic"
+)
+
+// setErrorLen is the room reserved for an error message beyond the pattern
+// itself, which re2 may include in the message.
+const setErrorLen = 64
+
+const (
+	errSetNotCompiled = "re2: Set must be compiled before matching"
+	errSetClosed      = "re2: use of closed Set"
+)
+
+var errSetCompiled = errors.New("re2: cannot add expression to a compiled Set")
+
+// Set is a collection of regular expressions that are matched against text
+// simultaneously, reporting which of them match. Matching a Set is
+// significantly faster than matching each expression individually.
+//
+// Expressions are added with Add and then Compile must be called before
+// matching. After compilation, a Set is safe for concurrent use by multiple
+// goroutines.
+type Set struct {
+	ptr uintptr
+
+	exprs    []string
+	compiled bool
+
+	abi *libre2ABI
+
+	released uint32
+}
+
+// NewSet returns an empty Set whose expressions are matched with the given
+// anchoring.
+func NewSet(anchor Anchor) *Set {
+	abi := sharedABI()
+	// The module is shared with Regexps, which may be in use.
+	abi.startOperation(0)
+	defer abi.endOperation()
+
+	set := &Set{
+		ptr: newSet(abi, anchor.cre2()),
+		abi: abi,
+	}
+
+	runtime.SetFinalizer(set, (*Set).release)
+
+	return set
+}
+
+// Add parses a regular expression and adds it to the Set, returning the
+// index that identifies it in the results of Match. Expressions may not be
+// added after the Set is compiled.
+func (s *Set) Add(expr string) (_ int, err error) {
+	defer recoverMemoryLimit(&err)
+
+	if s.compiled {
+		return -1, errSetCompiled
+	}
+
+	errLen := len(expr) + setErrorLen
+
+	s.startOperation(len(expr) + errLen)
+	defer s.abi.endOperation()
+
+	cs := newCString(s.abi, expr)
+
+	idx, msg := setAdd(s, cs, errLen)
+	if idx < 0 {
+		return -1, fmt.Errorf("error parsing regexp: %s", msg)
+	}
+
+	s.exprs = append(s.exprs, expr)
+	return idx, nil
+}
+
+// Compile prepares the Set for matching. It must be called after all
+// expressions have been added and before calling Match.
+func (s *Set) Compile() (err error) {
+	defer recoverMemoryLimit(&err)
+
+	s.startOperation(0)
+	defer s.abi.endOperation()
+
+	if !setCompile(s) {
+		return errors.New("re2: expression set too large")
+	}
+	s.compiled = true
+
+	return nil
+}
+
+// Len returns the number of expressions in the Set.
+func (s *Set) Len() int {
+	return len(s.exprs)
+}
+
+// Match returns the indexes, in ascending order, of the expressions in the
+// Set that match the byte slice b. A return value of nil indicates no match.
+func (s *Set) Match(b []byte) []int {
+	if !s.compiled {
+		panic(errSetNotCompiled)
+	}
+	if len(s.exprs) == 0 {
+		return nil
+	}
+
+	s.startOperation(len(b) + 4*len(s.exprs))
+	defer s.abi.endOperation()
+
+	cs := newCStringFromBytes(s.abi, b)
+
+	matches := setMatch(s, cs, len(s.exprs))
+	runtime.KeepAlive(b)
+	sort.Ints(matches)
+	return matches
+}
+
+// MatchString returns the indexes, in ascending order, of the expressions in
+// the Set that match the string str. A return value of nil indicates no match.
+func (s *Set) MatchString(str string) []int {
+	if !s.compiled {
+		panic(errSetNotCompiled)
+	}
+	if len(s.exprs) == 0 {
+		return nil
+	}
+
+	s.startOperation(len(str) + 4*len(s.exprs))
+	defer s.abi.endOperation()
+
+	cs := newCString(s.abi, str)
+
+	matches := setMatch(s, cs, len(s.exprs))
+	runtime.KeepAlive(str)
+	sort.Ints(matches)
+	return matches
+}
+
+// Close releases the memory used by re2 for the Set immediately, rather than
+// when the Set is garbage collected. Calling methods that need re2 after Close
+// panics. Close may be called multiple times, and may not be called
+// concurrently with any other methods.
+func (s *Set) Close() {
+	runtime.SetFinalizer(s, nil)
+	s.release()
+}
+
+// startOperation prepares the ABI for an operation on s, which must not be
+// closed.
+func (s *Set) startOperation(memorySize int) {
+	if atomic.LoadUint32(&s.released) == 1 {
+		panic(errSetClosed)
+	}
+	s.abi.startOperation(memorySize)
+}
+
+func (s *Set) release() {
+	if !atomic.CompareAndSwapUint32(&s.released, 0, 1) {
+		return
+	}
+	releaseSet(s)
+}
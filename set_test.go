@@ -0,0 +1,83 @@
+package re2
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type setTest struct {
+	anchor Anchor
+	exprs  []string
+	text   string
+	want   []int
+}
+
+var setTests = []setTest{
+	{Unanchored, []string{`foo`, `bar`}, "foobar", []int{0, 1}},
+	{Unanchored, []string{`foo`, `bar`}, "fooba", []int{0}},
+	{Unanchored, []string{`foo`, `bar`}, "oobar", []int{1}},
+	{Unanchored, []string{`foo`, `bar`}, "baz", nil},
+	{Unanchored, []string{`(foo|bar)`, `[a-z]+`, `\d+`}, "bar", []int{0, 1}},
+	{Unanchored, []string{`^foo`, `bar$`}, "xfoobar", []int{1}},
+	{AnchorStart, []string{`foo`, `bar`}, "foobar", []int{0}},
+	{AnchorStart, []string{`foo`, `bar`}, "barfoo", []int{1}},
+	{AnchorStart, []string{`foo`, `bar`}, "xfoobar", nil},
+	{AnchorBoth, []string{`foo`, `bar`, `foo.*`}, "foobar", []int{2}},
+	{AnchorBoth, []string{`foo`, `bar`}, "foo", []int{0}},
+	{AnchorBoth, []string{`foo`, `bar`}, "foox", nil},
+	{Unanchored, []string{`日本`, `語`}, "日本語", []int{0, 1}},
+}
+
+func TestSet(t *testing.T) {
+	for _, tc := range setTests {
+		set := NewSet(tc.anchor)
+		for i, expr := range tc.exprs {
+			idx, err := set.Add(expr)
+			if err != nil {
+				t.Fatalf("Add(%#q) unexpected error: %v", expr, err)
+			}
+			if idx != i {
+				t.Errorf("Add(%#q) = %d; want %d", expr, idx, i)
+			}
+		}
+		if err := set.Compile(); err != nil {
+			t.Fatalf("Compile() unexpected error: %v", err)
+		}
+		if got := set.MatchString(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v.MatchString(%q) = %v; want %v", tc.exprs, tc.text, got, tc.want)
+		}
+		if got := set.Match([]byte(tc.text)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v.Match(%q) = %v; want %v", tc.exprs, tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestSetBadAdd(t *testing.T) {
+	set := NewSet(Unanchored)
+	if _, err := set.Add(`(abc`); err == nil || !strings.Contains(err.Error(), "missing )") {
+		t.Errorf("Add(`(abc`) error = %v; want missing )", err)
+	}
+	if _, err := set.Add(`abc`); err != nil {
+		t.Fatalf("Add(`abc`) unexpected error: %v", err)
+	}
+	if err := set.Compile(); err != nil {
+		t.Fatalf("Compile() unexpected error: %v", err)
+	}
+	if _, err := set.Add(`def`); err == nil {
+		t.Error("Add after Compile: missing error")
+	}
+	if got, want := set.MatchString("xabcx"), []int{0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MatchString(%q) = %v; want %v", "xabcx", got, want)
+	}
+}
+
+func TestSetEmpty(t *testing.T) {
+	set := NewSet(Unanchored)
+	if err := set.Compile(); err != nil {
+		t.Fatalf("Compile() unexpected error: %v", err)
+	}
+	if got := set.MatchString("abc"); got != nil {
+		t.Errorf("MatchString(%q) = %v; want nil", "abc", got)
+	}
+}
@@ -0,0 +1,86 @@
+package re2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckTemplate reports whether template is a valid replacement template for
+// re, as used by Expand and ReplaceAll. Those methods silently drop
+// references to groups that do not exist and treat a malformed reference as
+// literal text; CheckTemplate instead returns an error describing the first
+// such problem, so that templates from configuration can be validated
+// before use. A template without problems returns nil.
+//
+// Note that as with Expand, a name is taken to be as long as possible, so
+// $1x refers to a group named "1x" and is reported as unknown unless re has
+// such a group.
+func (re *Regexp) CheckTemplate(template string) error {
+	subexpNames := re.SubexpNames()
+
+	offset := 0
+	for len(template) > 0 {
+		before, after, ok := strings.Cut(template, "$")
+		if !ok {
+			break
+		}
+		offset += len(before)
+		template = after
+		if template != "" && template[0] == '$' {
+			// $$ is a literal $.
+			template = template[1:]
+			offset += 2
+			continue
+		}
+		name, num, rest, ok := extract(template)
+		if !ok {
+			return fmt.Errorf("re2: malformed group reference at offset %d in template", offset)
+		}
+		ref := "$" + template[:len(template)-len(rest)]
+		template = rest
+		offset += len(ref)
+		if num < 0 {
+			found := false
+			for _, s := range subexpNames {
+				if s != "" && name == s {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("re2: template reference %s refers to unknown group name %q", ref, name)
+			}
+			continue
+		}
+		if num >= len(subexpNames) {
+			return fmt.Errorf("re2: template reference %s refers to group %d but expression has only %d groups", ref, num, len(subexpNames)-1)
+		}
+	}
+	return nil
+}
+
+// Extract returns the template with variables replaced by the text of the
+// leftmost match of re in src, dropping the text that did not match, and
+// reports whether there was a match. Inside template, $ signs are interpreted
+// as in Expand, so for instance $1 represents the text of the first submatch.
+// If there is no match, the result is nil.
+func (re *Regexp) Extract(src, template []byte) ([]byte, bool) {
+	match := re.FindSubmatchIndex(src)
+	if match == nil {
+		return nil, false
+	}
+	return re.Expand(nil, template, src, match), true
+}
+
+// ExtractString returns the template with variables replaced by the text of
+// the leftmost match of re in src, dropping the text that did not match, and
+// reports whether there was a match. Inside template, $ signs are interpreted
+// as in Expand, so for instance $1 represents the text of the first submatch.
+// If there is no match, the result is empty.
+func (re *Regexp) ExtractString(src, template string) (string, bool) {
+	match := re.FindStringSubmatchIndex(src)
+	if match == nil {
+		return "", false
+	}
+	return string(re.ExpandString(nil, template, src, match)), true
+}
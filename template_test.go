@@ -0,0 +1,74 @@
+package re2
+
+import "testing"
+
+func TestCheckTemplate(t *testing.T) {
+	re := MustCompile(`(?P<user>\w+)@(?P<host>[\w.]+)(:\d+)?`)
+
+	tests := []struct {
+		template string
+		err      string
+	}{
+		{"", ""},
+		{"plain", ""},
+		{"$0", ""},
+		{"$1@$2$3", ""},
+		{"${1}x", ""},
+		{"$user@$host", ""},
+		{"${user}_${host}", ""},
+		{"$$1", ""},
+		{"$$$user", ""},
+		{`\1`, ""},
+		{"$4", "re2: template reference $4 refers to group 4 but expression has only 3 groups"},
+		{"${10}", "re2: template reference ${10} refers to group 10 but expression has only 3 groups"},
+		{"$usr", `re2: template reference $usr refers to unknown group name "usr"`},
+		{"$1x", `re2: template reference $1x refers to unknown group name "1x"`},
+		{"$01", `re2: template reference $01 refers to unknown group name "01"`},
+		{"a$", "re2: malformed group reference at offset 1 in template"},
+		{"$user $-", "re2: malformed group reference at offset 6 in template"},
+		{"${user", "re2: malformed group reference at offset 0 in template"},
+		{"$$ ${}", "re2: malformed group reference at offset 3 in template"},
+	}
+
+	for _, tc := range tests {
+		err := re.CheckTemplate(tc.template)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.err {
+			t.Errorf("CheckTemplate(%q) = %q, want %q", tc.template, got, tc.err)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	re := MustCompile(`(?P<user>\w+)@(?P<host>[\w.]+)`)
+
+	tests := []struct {
+		src, template string
+		out           string
+		ok            bool
+	}{
+		{"login by alice@example.com from 10.0.0.1", "$user@$host", "alice@example.com", true},
+		{"login by alice@example.com", "${host}/$1", "example.com/alice", true},
+		{"bob@a and carol@b", "$user", "bob", true},
+		{"alice@example.com", "$$user", "$user", true},
+		{"alice@example.com", "$missing-$0", "-alice@example.com", true},
+		{"no address here", "$user@$host", "", false},
+	}
+
+	for _, tc := range tests {
+		out, ok := re.ExtractString(tc.src, tc.template)
+		if out != tc.out || ok != tc.ok {
+			t.Errorf("ExtractString(%q, %q) = (%q, %v), want (%q, %v)", tc.src, tc.template, out, ok, tc.out, tc.ok)
+		}
+		bout, ok := re.Extract([]byte(tc.src), []byte(tc.template))
+		if string(bout) != tc.out || ok != tc.ok {
+			t.Errorf("Extract(%q, %q) = (%q, %v), want (%q, %v)", tc.src, tc.template, bout, ok, tc.out, tc.ok)
+		}
+		if !ok && bout != nil {
+			t.Errorf("Extract(%q, %q) = %q, want nil", tc.src, tc.template, bout)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package re2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Limits bounds the cost of an expression compiled with CompileUntrusted,
+// such as one provided by a user. A zero field does not limit anything.
+type Limits struct {
+	// MaxPatternLength is the maximum length in bytes of the expression.
+	MaxPatternLength int
+
+	// MaxRepeat is the maximum number of times a counted repetition such as
+	// a{10,20} may repeat its argument, multiplied across nested repetitions,
+	// so that (a{10}){20} repeats a 200 times. It is checked before compiling,
+	// so an expression repeating too much never uses the memory to compile it.
+	// re2 itself rejects expressions repeating more than 1000 times.
+	MaxRepeat int
+
+	// MaxMem is the approximate number of bytes of memory the compiled
+	// expression may use, as with Options.MaxMem.
+	MaxMem int64
+
+	// MaxCaptureGroups is the maximum number of capturing groups.
+	MaxCaptureGroups int
+
+	// MaxProgramSize is the maximum size of the compiled program, as returned
+	// by ProgramSize.
+	MaxProgramSize int
+}
+
+// Limit identifies a field of Limits.
+type Limit int
+
+const (
+	// LimitPatternLength is Limits.MaxPatternLength.
+	LimitPatternLength Limit = iota + 1
+	// LimitRepeat is Limits.MaxRepeat.
+	LimitRepeat
+	// LimitMaxMem is Limits.MaxMem.
+	LimitMaxMem
+	// LimitCaptureGroups is Limits.MaxCaptureGroups.
+	LimitCaptureGroups
+	// LimitProgramSize is Limits.MaxProgramSize.
+	LimitProgramSize
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitPatternLength:
+		return "pattern length"
+	case LimitRepeat:
+		return "repetition count"
+	case LimitMaxMem:
+		return "memory"
+	case LimitCaptureGroups:
+		return "capture groups"
+	case LimitProgramSize:
+		return "program size"
+	}
+	return fmt.Sprintf("Limit(%d)", int(l))
+}
+
+// LimitError is returned by CompileUntrusted for an expression that exceeds
+// one of its Limits.
+type LimitError struct {
+	// Limit is the limit that was exceeded.
+	Limit Limit
+	// Value is the value of the expression that exceeded the limit. It is
+	// zero for LimitMaxMem, as re2 stops compiling without reporting how
+	// much memory the expression needs.
+	Value int64
+	// Max is the limit that was exceeded.
+	Max int64
+	// Pattern is the expression.
+	Pattern string
+}
+
+func (e *LimitError) Error() string {
+	if e.Limit == LimitMaxMem {
+		return fmt.Sprintf("re2: expression does not fit in memory limit of %d bytes", e.Max)
+	}
+	return fmt.Sprintf("re2: expression %s %d exceeds limit of %d", e.Limit, e.Value, e.Max)
+}
+
+// CompileUntrusted is like Compile but fails with a *LimitError if the
+// expression exceeds any of limits, for compiling expressions from untrusted
+// sources. Limits that can be checked without compiling are checked first.
+func CompileUntrusted(expr string, limits Limits) (*Regexp, error) {
+	if limits.MaxPatternLength > 0 && len(expr) > limits.MaxPatternLength {
+		return nil, &LimitError{Limit: LimitPatternLength, Value: int64(len(expr)), Max: int64(limits.MaxPatternLength), Pattern: expr}
+	}
+
+	if limits.MaxRepeat > 0 {
+		if n := maxRepeat(expr); n > int64(limits.MaxRepeat) {
+			return nil, &LimitError{Limit: LimitRepeat, Value: n, Max: int64(limits.MaxRepeat), Pattern: expr}
+		}
+	}
+
+	re, err := compile(expr, Options{MaxMem: limits.MaxMem})
+	if err != nil {
+		var reErr *Error
+		if limits.MaxMem > 0 && errors.As(err, &reErr) && reErr.Code == errLarge {
+			return nil, &LimitError{Limit: LimitMaxMem, Max: limits.MaxMem, Pattern: expr}
+		}
+		return nil, err
+	}
+	if n := re.NumSubexp(); limits.MaxCaptureGroups > 0 && n > limits.MaxCaptureGroups {
+		re.Close()
+		return nil, &LimitError{Limit: LimitCaptureGroups, Value: int64(n), Max: int64(limits.MaxCaptureGroups), Pattern: expr}
+	}
+
+	if limits.MaxProgramSize > 0 {
+		if n := re.ProgramSize(); n > limits.MaxProgramSize {
+			re.Close()
+			return nil, &LimitError{Limit: LimitProgramSize, Value: int64(n), Max: int64(limits.MaxProgramSize), Pattern: expr}
+		}
+	}
+
+	return re, nil
+}
+
+// maxRepeatCount caps the counts computed by maxRepeat, far above what re2
+// accepts, so that deeply nested repetitions cannot overflow.
+const maxRepeatCount = 1 << 40
+
+// maxRepeat returns the largest number of times expr repeats any expression,
+// multiplying the counts of nested repetitions. Unbounded repetitions count
+// their minimum, as they compile into a loop. It scans the expression rather
+// than parsing it with regexp/syntax, which rejects repetitions above 1000
+// before they can be counted, and does not validate it, leaving that to re2.
+func maxRepeat(expr string) int64 {
+	// Each group has the largest count inside it so far, and last is the
+	// count of the most recent atom, which a repetition multiplies.
+	type group struct{ max, last int64 }
+	stack := []group{{}}
+	top := &stack[0]
+
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; c {
+		case '\\':
+			i = skipEscape(expr, i)
+			top.last = 0
+		case '[':
+			i = skipClass(expr, i)
+			top.last = 0
+		case '(':
+			stack = append(stack, group{})
+			top = &stack[len(stack)-1]
+		case ')':
+			if len(stack) == 1 {
+				top.last = 0
+				continue
+			}
+			inner := top.max
+			stack = stack[:len(stack)-1]
+			top = &stack[len(stack)-1]
+			top.last = inner
+			if inner > top.max {
+				top.max = inner
+			}
+		case '{':
+			count, end, ok := parseRepeat(expr, i)
+			if !ok {
+				top.last = 0
+				continue
+			}
+			i = end
+			if top.last > 0 {
+				count *= top.last
+			}
+			if count > maxRepeatCount {
+				count = maxRepeatCount
+			}
+			top.last = count
+			if count > top.max {
+				top.max = count
+			}
+		default:
+			if c != '*' && c != '+' && c != '?' {
+				top.last = 0
+			}
+		}
+	}
+
+	max := int64(0)
+	for _, g := range stack {
+		if g.max > max {
+			max = g.max
+		}
+	}
+	return max
+}
+
+// parseRepeat parses a counted repetition {n}, {n,} or {n,m} starting at
+// expr[i], returning the count it repeats by, m or else n, and the index of
+// its closing brace. ok is false if expr[i] starts a literal brace instead.
+func parseRepeat(expr string, i int) (count int64, end int, ok bool) {
+	var min, max int64
+	j := i + 1
+	min, j, ok = parseCount(expr, j)
+	if !ok {
+		return 0, 0, false
+	}
+	max = min
+	if j < len(expr) && expr[j] == ',' {
+		j++
+		if j < len(expr) && expr[j] != '}' {
+			max, j, ok = parseCount(expr, j)
+			if !ok {
+				return 0, 0, false
+			}
+		}
+	}
+	if j >= len(expr) || expr[j] != '}' {
+		return 0, 0, false
+	}
+	return max, j, true
+}
+
+// parseCount parses the decimal number at expr[i], capped at maxRepeatCount,
+// returning the index after it.
+func parseCount(expr string, i int) (n int64, end int, ok bool) {
+	start := i
+	for ; i < len(expr) && expr[i] >= '0' && expr[i] <= '9'; i++ {
+		if n < maxRepeatCount {
+			n = n*10 + int64(expr[i]-'0')
+		}
+	}
+	return n, i, i > start
+}
+
+// skipEscape returns the index of the last byte of the escape sequence
+// starting with the backslash at expr[i], including the braces of \p{Greek}
+// and \x{10FFFF} and the literal text of \Q...\E.
+func skipEscape(expr string, i int) int {
+	if i+1 >= len(expr) {
+		return i
+	}
+	switch expr[i+1] {
+	case 'p', 'P', 'x':
+		if i+2 < len(expr) && expr[i+2] == '{' {
+			if end := strings.IndexByte(expr[i+2:], '}'); end >= 0 {
+				return i + 2 + end
+			}
+			return len(expr) - 1
+		}
+	case 'Q':
+		if end := strings.Index(expr[i+2:], `\E`); end >= 0 {
+			return i + 2 + end + 1
+		}
+		return len(expr) - 1
+	}
+	return i + 1
+}
+
+// skipClass returns the index of the bracket closing the character class
+// starting at expr[i], which may contain escapes and classes like [:alpha:].
+func skipClass(expr string, i int) int {
+	j := i + 1
+	if j < len(expr) && expr[j] == '^' {
+		j++
+	}
+	// A bracket right after the opening one is literal.
+	if j < len(expr) && expr[j] == ']' {
+		j++
+	}
+	for ; j < len(expr); j++ {
+		switch expr[j] {
+		case '\\':
+			j = skipEscape(expr, j)
+		case '[':
+			if j+1 < len(expr) && expr[j+1] == ':' {
+				if end := strings.Index(expr[j+2:], ":]"); end >= 0 {
+					j += 2 + end + 1
+				}
+			}
+		case ']':
+			return j
+		}
+	}
+	return len(expr) - 1
+}
@@ -0,0 +1,86 @@
+package re2
+
+import (
+	"errors"
+	"regexp/syntax"
+	"strings"
+	"testing"
+)
+
+func TestCompileUntrusted(t *testing.T) {
+	tests := []struct {
+		expr   string
+		limits Limits
+		limit  Limit
+		value  int64
+	}{
+		{expr: `abc`, limits: Limits{MaxPatternLength: 3}},
+		{expr: `abcd`, limits: Limits{MaxPatternLength: 3}, limit: LimitPatternLength, value: 4},
+		{expr: `a{100}`, limits: Limits{MaxRepeat: 100}},
+		{expr: `a{101,}`, limits: Limits{MaxRepeat: 100}, limit: LimitRepeat, value: 101},
+		{expr: `(a{100}){5}`, limits: Limits{MaxRepeat: 100}, limit: LimitRepeat, value: 500},
+		{expr: `(a{10}|b{20}){5,10}`, limits: Limits{MaxRepeat: 200}},
+		{expr: `(a{10}|b{20}){5,11}`, limits: Limits{MaxRepeat: 200}, limit: LimitRepeat, value: 220},
+		{expr: `(a{600})`, limits: Limits{MaxRepeat: 500}, limit: LimitRepeat, value: 600},
+		{expr: `(?:a{600})`, limits: Limits{MaxRepeat: 500}, limit: LimitRepeat, value: 600},
+		{expr: `x(a{600})y`, limits: Limits{MaxRepeat: 500}, limit: LimitRepeat, value: 600},
+		{expr: `((a{10})b){10}`, limits: Limits{MaxRepeat: 50}, limit: LimitRepeat, value: 100},
+		{expr: `(a{500})|(?:b{500})`, limits: Limits{MaxRepeat: 500}},
+		{expr: `a{1001}`, limits: Limits{MaxRepeat: 500}, limit: LimitRepeat, value: 1001},
+		{expr: `(a{100}){100}`, limits: Limits{MaxRepeat: 500}, limit: LimitRepeat, value: 10000},
+		{expr: `(a{1000}){1000}`, limits: Limits{MaxRepeat: 500}, limit: LimitRepeat, value: 1000000},
+		{expr: `[{]{501}`, limits: Limits{MaxRepeat: 500}, limit: LimitRepeat, value: 501},
+		{expr: `\{1000}[a{1000}]\Q{1000}\Ex{,1000}`, limits: Limits{MaxRepeat: 500}},
+		{expr: `\pL{10}`, limits: Limits{MaxMem: 1 << 20}},
+		{expr: `\pL{100}`, limits: Limits{MaxMem: 1 << 14}, limit: LimitMaxMem},
+		{expr: `(a)(b)`, limits: Limits{MaxCaptureGroups: 2}},
+		{expr: `(a)(b)(?:c)(d)`, limits: Limits{MaxCaptureGroups: 2}, limit: LimitCaptureGroups, value: 3},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.expr, func(t *testing.T) {
+			re, err := CompileUntrusted(tt.expr, tt.limits)
+			if tt.limit == 0 {
+				if err != nil {
+					t.Fatalf("CompileUntrusted(%#q, %+v) error: %v", tt.expr, tt.limits, err)
+				}
+				re.Close()
+				return
+			}
+
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("CompileUntrusted(%#q, %+v) = %v, want *LimitError", tt.expr, tt.limits, err)
+			}
+			if limitErr.Limit != tt.limit || limitErr.Value != tt.value || limitErr.Pattern != tt.expr {
+				t.Errorf("CompileUntrusted(%#q, %+v) = %+v, want %v of %d", tt.expr, tt.limits, limitErr, tt.limit, tt.value)
+			}
+			if !strings.Contains(err.Error(), tt.limit.String()) {
+				t.Errorf("error %q does not mention %q", err, tt.limit)
+			}
+		})
+	}
+}
+
+func TestCompileUntrustedInvalid(t *testing.T) {
+	_, err := CompileUntrusted(`a(`, Limits{MaxRepeat: 10})
+	var reErr *Error
+	if !errors.As(err, &reErr) || reErr.Code != syntax.ErrMissingParen {
+		t.Errorf("CompileUntrusted(`a(`) = %v, want missing paren error", err)
+	}
+}
+
+func TestCompileUntrustedProgramSize(t *testing.T) {
+	re, err := CompileUntrusted(`a{10}`, Limits{MaxProgramSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	re.Close()
+
+	_, err = CompileUntrusted(`a{100}`, Limits{MaxProgramSize: 100})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != LimitProgramSize || limitErr.Value <= 100 {
+		t.Errorf("CompileUntrusted(`a{100}`) = %v, want program size error", err)
+	}
+}
@@ -0,0 +1,121 @@
+package re2
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// MatchAt reports whether the window b[start:end] contains any match of the
+// regular expression re. Unlike matching b[start:end] directly, the text
+// outside the window is visible to zero-width assertions, so ^ and \A only
+// match at the start of b, $ and \z only at its end, and \b considers the
+// bytes adjacent to the window.
+func (re *Regexp) MatchAt(b []byte, start, end int) bool {
+	return re.matchAt(b, "", len(b), start, end)
+}
+
+// MatchStringAt reports whether the window s[start:end] contains any match of
+// the regular expression re, with the text outside the window visible to
+// zero-width assertions as described for MatchAt.
+func (re *Regexp) MatchStringAt(s string, start, end int) bool {
+	return re.matchAt(nil, s, len(s), start, end)
+}
+
+// FindIndexAt returns a two-element slice of integers defining the location of
+// the leftmost match of the regular expression within the window b[start:end],
+// with the text outside the window visible to zero-width assertions as
+// described for MatchAt. The match itself is at b[loc[0]:loc[1]]; indexes are
+// relative to b, not to the window.
+// A return value of nil indicates no match.
+func (re *Regexp) FindIndexAt(b []byte, start, end int) (loc []int) {
+	return re.findIndexAt(b, "", len(b), start, end)
+}
+
+// FindStringIndexAt returns a two-element slice of integers defining the
+// location of the leftmost match of the regular expression within the window
+// s[start:end], with the text outside the window visible to zero-width
+// assertions as described for MatchAt. The match itself is at
+// s[loc[0]:loc[1]]; indexes are relative to s, not to the window.
+// A return value of nil indicates no match.
+func (re *Regexp) FindStringIndexAt(s string, start, end int) (loc []int) {
+	return re.findIndexAt(nil, s, len(s), start, end)
+}
+
+// FindSubmatchIndexAt returns a slice holding the index pairs identifying the
+// leftmost match of the regular expression within the window b[start:end] and
+// the matches, if any, of its subexpressions, as defined by the 'Submatch' and
+// 'Index' descriptions in the package comment. The text outside the window is
+// visible to zero-width assertions as described for MatchAt, and indexes are
+// relative to b, not to the window.
+// A return value of nil indicates no match.
+func (re *Regexp) FindSubmatchIndexAt(b []byte, start, end int) []int {
+	return re.findSubmatchIndexAt(b, "", len(b), start, end)
+}
+
+// FindStringSubmatchIndexAt returns a slice holding the index pairs
+// identifying the leftmost match of the regular expression within the window
+// s[start:end] and the matches, if any, of its subexpressions, as defined by
+// the 'Submatch' and 'Index' descriptions in the package comment. The text
+// outside the window is visible to zero-width assertions as described for
+// MatchAt, and indexes are relative to s, not to the window.
+// A return value of nil indicates no match.
+func (re *Regexp) FindStringSubmatchIndexAt(s string, start, end int) []int {
+	return re.findSubmatchIndexAt(nil, s, len(s), start, end)
+}
+
+func checkWindow(start, end, length int) {
+	if start < 0 || end < start || end > length {
+		panic(fmt.Sprintf("re2: window [%d:%d] out of range with length %d", start, end, length))
+	}
+}
+
+func (re *Regexp) matchAt(bsrc []byte, src string, length int, start, end int) bool {
+	checkWindow(start, end, length)
+
+	inst := re.startOperation(length)
+	defer inst.abi.endOperation()
+
+	cs := newCStringFromEither(inst.abi, bsrc, src)
+	res := matchFrom(inst, cs, start, end, 0, 0)
+	runtime.KeepAlive(bsrc)
+	runtime.KeepAlive(src)
+	return res
+}
+
+func (re *Regexp) findIndexAt(bsrc []byte, src string, length int, start, end int) []int {
+	checkWindow(start, end, length)
+
+	inst := re.startOperation(length + 8)
+	defer inst.abi.endOperation()
+
+	cs := newCStringFromEither(inst.abi, bsrc, src)
+	matchArr := newCStringArray(inst.abi, 1)
+
+	if !matchFrom(inst, cs, start, end, matchArr.ptr, 1) {
+		return nil
+	}
+
+	return readMatch(inst.abi, cs, matchArr.ptr, nil)
+}
+
+func (re *Regexp) findSubmatchIndexAt(bsrc []byte, src string, length int, start, end int) []int {
+	checkWindow(start, end, length)
+
+	inst := re.startOperation(length + 8*re.numMatches)
+	defer inst.abi.endOperation()
+
+	cs := newCStringFromEither(inst.abi, bsrc, src)
+	numGroups := re.numMatches
+	matchArr := newCStringArray(inst.abi, numGroups)
+
+	if !matchFrom(inst, cs, start, end, matchArr.ptr, uint32(numGroups)) {
+		return nil
+	}
+
+	var matches []int
+	readMatches(inst.abi, cs, matchArr.ptr, numGroups, func(match []int) {
+		matches = append(matches, match...)
+	})
+
+	return matches
+}
@@ -0,0 +1,80 @@
+package re2
+
+import (
+	"reflect"
+	"testing"
+)
+
+var windowTests = []struct {
+	pat        string
+	text       string
+	start, end int
+	loc        []int
+}{
+	{`foo`, "xfoo foo", 0, 8, []int{1, 4}},
+	{`foo`, "xfoo foo", 2, 8, []int{5, 8}},
+	{`foo`, "xfoo foo", 1, 3, nil},
+	// Word boundaries see the text around the window.
+	{`\bfoo\b`, "xfoo foo", 1, 4, nil},
+	{`\bfoo\b`, "xfoo foo", 5, 8, []int{5, 8}},
+	{`\bfoo\b`, "foox", 0, 3, nil},
+	{`\bfoo\b`, "foo x", 0, 3, []int{0, 3}},
+	// So do the start and end of text.
+	{`^a`, "ba", 1, 2, nil},
+	{`^a`, "ab", 0, 1, []int{0, 1}},
+	{`a$`, "ab", 0, 1, nil},
+	{`a$`, "ba", 1, 2, []int{1, 2}},
+	{`(?m)^a`, "b\na", 2, 3, []int{2, 3}},
+	{`a*`, "bba", 1, 1, []int{1, 1}},
+	{`(a)(b)?`, "xab", 1, 2, []int{1, 2, 1, 2, -1, -1}},
+	{`(a)(b)?`, "xab", 1, 3, []int{1, 3, 1, 2, 2, 3}},
+}
+
+func TestMatchAt(t *testing.T) {
+	for _, tc := range windowTests {
+		re := MustCompile(tc.pat)
+
+		if got, want := re.MatchAt([]byte(tc.text), tc.start, tc.end), tc.loc != nil; got != want {
+			t.Errorf("%q.MatchAt(%q, %d, %d) = %v, want %v", tc.pat, tc.text, tc.start, tc.end, got, want)
+		}
+		if got, want := re.MatchStringAt(tc.text, tc.start, tc.end), tc.loc != nil; got != want {
+			t.Errorf("%q.MatchStringAt(%q, %d, %d) = %v, want %v", tc.pat, tc.text, tc.start, tc.end, got, want)
+		}
+
+		var loc []int
+		if tc.loc != nil {
+			loc = tc.loc[:2]
+		}
+		if got := re.FindIndexAt([]byte(tc.text), tc.start, tc.end); !reflect.DeepEqual(got, loc) {
+			t.Errorf("%q.FindIndexAt(%q, %d, %d) = %v, want %v", tc.pat, tc.text, tc.start, tc.end, got, loc)
+		}
+		if got := re.FindStringIndexAt(tc.text, tc.start, tc.end); !reflect.DeepEqual(got, loc) {
+			t.Errorf("%q.FindStringIndexAt(%q, %d, %d) = %v, want %v", tc.pat, tc.text, tc.start, tc.end, got, loc)
+		}
+
+		want := tc.loc
+		if want != nil && len(want) < 2*(re.NumSubexp()+1) {
+			t.Fatalf("bad test %q: want %d indexes", tc.pat, 2*(re.NumSubexp()+1))
+		}
+		if got := re.FindSubmatchIndexAt([]byte(tc.text), tc.start, tc.end); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q.FindSubmatchIndexAt(%q, %d, %d) = %v, want %v", tc.pat, tc.text, tc.start, tc.end, got, want)
+		}
+		if got := re.FindStringSubmatchIndexAt(tc.text, tc.start, tc.end); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q.FindStringSubmatchIndexAt(%q, %d, %d) = %v, want %v", tc.pat, tc.text, tc.start, tc.end, got, want)
+		}
+	}
+}
+
+func TestMatchAtOutOfRange(t *testing.T) {
+	re := MustCompile(`a`)
+	for _, w := range [][2]int{{-1, 1}, {2, 1}, {0, 4}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("MatchStringAt(%q, %d, %d) did not panic", "abc", w[0], w[1])
+				}
+			}()
+			re.MatchStringAt("abc", w[0], w[1])
+		}()
+	}
+}